@@ -0,0 +1,226 @@
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// redacted replaces the values of redacted keys in AdminHandler responses.
+const redacted = "[REDACTED]"
+
+// adminSession is the JSON representation of a session in AdminHandler responses.
+type adminSession struct {
+	ID             string                 `json:"id"`
+	Name           string                 `json:"name"`
+	UserID         string                 `json:"user_id,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+	LastAccessedAt time.Time              `json:"last_accessed_at"`
+	ExpiresAt      time.Time              `json:"expires_at"`
+	IP             string                 `json:"ip,omitempty"`
+	UserAgentHash  string                 `json:"user_agent_hash,omitempty"`
+	Values         map[string]interface{} `json:"values,omitempty"`
+}
+
+type adminHandler struct {
+	store      Store
+	authorize  func(r *http.Request) bool
+	redactKeys map[string]bool
+}
+
+// AdminHandler returns a handler exposing JSON endpoints to inspect and revoke sessions in store:
+//
+//	GET    /sessions?cursor=&limit=   list sessions (metadata only), store must implement Inspector
+//	GET    /sessions/{id}             view the metadata and values of a session
+//	DELETE /sessions/{id}             delete a session
+//	GET    /users/{id}/sessions       list the sessions of a user, store must implement UserIndex
+//	DELETE /users/{id}/sessions       revoke all sessions of a user
+//
+// Every request must pass authorize, otherwise it's rejected with 403.
+// The values of redactKeys are never returned.
+// Mount it under a prefix with http.StripPrefix, session IDs in paths must be URL escaped.
+func AdminHandler(store Store, authorize func(r *http.Request) bool, redactKeys ...string) http.Handler {
+	h := &adminHandler{
+		store:      store,
+		authorize:  authorize,
+		redactKeys: make(map[string]bool, len(redactKeys)),
+	}
+	for _, key := range redactKeys {
+		h.redactKeys[key] = true
+	}
+	return h
+}
+
+func (h *adminHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.authorize == nil || !h.authorize(r) {
+		writeAdminError(w, http.StatusForbidden, "forbidden")
+		return
+	}
+
+	// Split the escaped path, so IDs containing '/' once unescaped stay in one segment.
+	var segments []string
+	for _, segment := range strings.Split(strings.Trim(r.URL.EscapedPath(), "/"), "/") {
+		unescaped, err := url.PathUnescape(segment)
+		if err != nil {
+			writeAdminError(w, http.StatusBadRequest, "invalid path")
+			return
+		}
+		segments = append(segments, unescaped)
+	}
+
+	switch {
+	case len(segments) == 1 && segments[0] == "sessions" && r.Method == http.MethodGet:
+		h.list(w, r)
+	case len(segments) == 2 && segments[0] == "sessions" && r.Method == http.MethodGet:
+		h.view(w, segments[1])
+	case len(segments) == 2 && segments[0] == "sessions" && r.Method == http.MethodDelete:
+		h.delete(w, segments[1])
+	case len(segments) == 3 && segments[0] == "users" && segments[2] == "sessions" && r.Method == http.MethodGet:
+		h.listByUser(w, segments[1])
+	case len(segments) == 3 && segments[0] == "users" && segments[2] == "sessions" && r.Method == http.MethodDelete:
+		h.revokeUser(w, segments[1])
+	default:
+		writeAdminError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (h *adminHandler) list(w http.ResponseWriter, r *http.Request) {
+	inspector, ok := h.store.(Inspector)
+	if !ok {
+		writeAdminError(w, http.StatusNotImplemented, "store doesn't support listing sessions")
+		return
+	}
+	limit := 50
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 || n > 1000 {
+			writeAdminError(w, http.StatusBadRequest, "limit must be between 1 and 1000")
+			return
+		}
+		limit = n
+	}
+
+	sessions, next, err := inspector.List(r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	views := make([]adminSession, len(sessions))
+	for i, session := range sessions {
+		views[i] = h.toView(session, false)
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{"sessions": views, "next": next})
+}
+
+func (h *adminHandler) view(w http.ResponseWriter, id string) {
+	inspector, ok := h.store.(Inspector)
+	if !ok {
+		writeAdminError(w, http.StatusNotImplemented, "store doesn't support loading sessions by id")
+		return
+	}
+	session, err := inspector.Load(id)
+	if err != nil {
+		writeAdminLoadError(w, err)
+		return
+	}
+	writeAdminJSON(w, http.StatusOK, h.toView(session, true))
+}
+
+// delete loads the session and removes it with Store.Delete,
+// so stores keep their indexes, e.g. the sessions of a user, consistent.
+func (h *adminHandler) delete(w http.ResponseWriter, id string) {
+	inspector, ok := h.store.(Inspector)
+	if !ok {
+		writeAdminError(w, http.StatusNotImplemented, "store doesn't support loading sessions by id")
+		return
+	}
+	session, err := inspector.Load(id)
+	if err != nil {
+		writeAdminLoadError(w, err)
+		return
+	}
+	if err := h.store.Delete(session); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *adminHandler) listByUser(w http.ResponseWriter, userID string) {
+	index, ok := h.store.(UserIndex)
+	if !ok {
+		writeAdminError(w, http.StatusNotImplemented, "store doesn't support user bindings")
+		return
+	}
+	sessions, err := index.ListByUser(userID)
+	if err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	views := make([]adminSession, len(sessions))
+	for i, session := range sessions {
+		views[i] = h.toView(session, false)
+	}
+	writeAdminJSON(w, http.StatusOK, map[string]interface{}{"sessions": views})
+}
+
+func (h *adminHandler) revokeUser(w http.ResponseWriter, userID string) {
+	index, ok := h.store.(UserIndex)
+	if !ok {
+		writeAdminError(w, http.StatusNotImplemented, "store doesn't support user bindings")
+		return
+	}
+	if err := index.RevokeUser(userID); err != nil {
+		writeAdminError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// toView returns the JSON representation of the session, values are only included if withValues is set.
+func (h *adminHandler) toView(session *Session, withValues bool) adminSession {
+	session.mutex.RLock()
+	defer session.mutex.RUnlock()
+	view := adminSession{
+		ID:             session.data.ID,
+		Name:           session.data.Name,
+		UserID:         session.data.UserID,
+		CreatedAt:      time.Unix(session.data.CreatedAt, 0),
+		LastAccessedAt: time.Unix(session.data.LastAccessedAt, 0),
+		ExpiresAt:      time.Unix(session.data.Expiry, 0),
+		IP:             session.data.IP,
+		UserAgentHash:  session.data.UserAgentHash,
+	}
+	if withValues {
+		view.Values = make(map[string]interface{}, len(session.data.Values))
+		for k, v := range session.data.Values {
+			if h.redactKeys[k] {
+				v = redacted
+			}
+			view.Values[k] = v
+		}
+	}
+	return view
+}
+
+func writeAdminLoadError(w http.ResponseWriter, err error) {
+	if errors.Is(err, ErrNotFound) {
+		writeAdminError(w, http.StatusNotFound, "session not found")
+		return
+	}
+	writeAdminError(w, http.StatusInternalServerError, err.Error())
+}
+
+func writeAdminError(w http.ResponseWriter, code int, message string) {
+	writeAdminJSON(w, code, map[string]string{"error": message})
+}
+
+func writeAdminJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,62 @@
+package sessions
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdminHandler(t *testing.T) {
+	store, _ := NewMemoryStore()
+	session, _ := store.New("session-key")
+	session.SetValue("name", "Coco")
+	session.SetValue("token", "secret")
+	_ = store.(UserIndex).BindUser(session, "coco")
+	other, _ := store.New("session-key")
+
+	handler := http.StripPrefix("/admin", AdminHandler(store, func(r *http.Request) bool {
+		return r.Header.Get("Authorization") == "Bearer admin"
+	}, "token"))
+	serve := func(method, path string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer admin")
+		rsp := httptest.NewRecorder()
+		handler.ServeHTTP(rsp, req)
+		return rsp
+	}
+	sessionPath := "/admin/sessions/" + url.PathEscape(session.GetID())
+
+	// Unauthorized requests are rejected.
+	rsp := httptest.NewRecorder()
+	handler.ServeHTTP(rsp, httptest.NewRequest(http.MethodGet, "/admin/sessions", nil))
+	assert.Equal(t, http.StatusForbidden, rsp.Code)
+
+	rsp = serve(http.MethodGet, "/admin/sessions?limit=10")
+	assert.Equal(t, http.StatusOK, rsp.Code)
+	var page struct {
+		Sessions []adminSession `json:"sessions"`
+		Next     string         `json:"next"`
+	}
+	assert.NoError(t, json.NewDecoder(rsp.Body).Decode(&page))
+	assert.Len(t, page.Sessions, 2)
+	assert.Empty(t, page.Sessions[0].Values, "values are only shown when viewing a session")
+
+	rsp = serve(http.MethodGet, sessionPath)
+	assert.Equal(t, http.StatusOK, rsp.Code)
+	var view adminSession
+	assert.NoError(t, json.NewDecoder(rsp.Body).Decode(&view))
+	assert.Equal(t, "coco", view.UserID)
+	assert.Equal(t, "Coco", view.Values["name"])
+	assert.Equal(t, redacted, view.Values["token"])
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/admin/users/coco/sessions").Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodGet, sessionPath).Code)
+
+	assert.Equal(t, http.StatusNoContent, serve(http.MethodDelete, "/admin/sessions/"+url.PathEscape(other.GetID())).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodDelete, "/admin/sessions/"+url.PathEscape(other.GetID())).Code)
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/admin/sessions").Code)
+}
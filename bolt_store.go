@@ -0,0 +1,352 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	// boltSessionsBucket maps session IDs to the expiry (8 bytes, big endian) followed by the serialized session.
+	boltSessionsBucket = []byte("sessions")
+	// boltExpiryBucket indexes sessions by expiry, keys are the expiry followed by the session ID,
+	// so expired sessions are found by a cursor scan from the start.
+	boltExpiryBucket = []byte("sessions_expiry")
+)
+
+// BoltStore represents a session store backed by an embedded bbolt database,
+// for nodes that need durable sessions without an external database.
+type BoltStore struct {
+	*baseStore
+	db         *bolt.DB
+	serializer Codec
+	gcInterval time.Duration
+}
+
+// NewBoltStore creates a BoltStore on db, the buckets are created if they don't exist.
+// The caller owns db and is responsible for closing it.
+func NewBoltStore(db *bolt.DB, options ...func(store *BoltStore)) (Store, error) {
+	base, err := newBaseStore(defaultOptions(), 16)
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		if _, err := tx.CreateBucketIfNotExists(boltSessionsBucket); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucketIfNotExists(boltExpiryBucket)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+
+	store := &BoltStore{
+		baseStore:  base,
+		db:         db,
+		serializer: &Serializer{},
+		gcInterval: time.Minute,
+	}
+
+	for _, op := range options {
+		op(store)
+	}
+
+	go store.gc()
+
+	return store, nil
+}
+
+// Option functions for customizing BoltStore
+
+// WithBoltOptions sets the cookie options for the store
+func WithBoltOptions(options *Options) func(store *BoltStore) {
+	return func(store *BoltStore) {
+		if options == nil {
+			return
+		}
+		if err := options.Validate(); err != nil {
+			panic(err)
+		}
+		store.options = options
+	}
+}
+
+// WithBoltSessionIDLength sets the session ID length
+func WithBoltSessionIDLength(length int) func(store *BoltStore) {
+	return func(store *BoltStore) {
+		if length <= 0 {
+			panic("session ID length must be greater than 0")
+		}
+		store.idLength = length
+	}
+}
+
+// WithBoltGCInterval sets the interval of deleting expired sessions
+func WithBoltGCInterval(interval time.Duration) func(store *BoltStore) {
+	return func(store *BoltStore) {
+		if interval <= 0 {
+			panic("GC interval must be greater than 0")
+		}
+		store.gcInterval = interval
+	}
+}
+
+// WithBoltCodec sets the codec sessions are written into the database with, the JSON Serializer by default.
+func WithBoltCodec(codec Codec) func(store *BoltStore) {
+	return func(store *BoltStore) {
+		if codec == nil {
+			panic("codec cannot be nil")
+		}
+		store.serializer = codec
+	}
+}
+
+// WithBoltLimits limits the size of sessions, see WithLimits. MaxSize applies to sessions written by the codec.
+func WithBoltLimits(limits Limits) func(store *BoltStore) {
+	return func(store *BoltStore) {
+		store.limits = validateLimits(limits)
+	}
+}
+
+// WithBoltTrustedProxies sets the proxies whose X-Forwarded-For header is trusted
+// when capturing the client IP of a session.
+func WithBoltTrustedProxies(proxies ...string) func(store *BoltStore) {
+	return func(store *BoltStore) {
+		networks, err := parseCIDRs(proxies)
+		if err != nil {
+			panic(err)
+		}
+		store.trustedProxies = networks
+	}
+}
+
+// WithBoltFingerprint binds every new session to a fingerprint of its client,
+// see FingerprintPolicy.
+func WithBoltFingerprint(policy FingerprintPolicy) func(store *BoltStore) {
+	return func(store *BoltStore) {
+		store.fingerprint = &policy
+	}
+}
+
+// WithBoltLogger sets the logger of the store, see WithLogger.
+func WithBoltLogger(logger *slog.Logger) func(store *BoltStore) {
+	return func(store *BoltStore) {
+		store.setLogger(logger)
+	}
+}
+
+// Get retrieves a session by name from the database or creates a new one.
+func (s *BoltStore) Get(r *http.Request, name string) (*Session, error) {
+	return s.getSession(s, r, name)
+}
+
+// New creates a new session and saves it into the database.
+func (s *BoltStore) New(name string) (*Session, error) {
+	return s.createSession(s, nil, name)
+}
+
+// Save writes the session and updates its entry in the expiry index.
+func (s *BoltStore) Save(session *Session) error {
+	data, err := s.serializer.Serialize(session)
+	if err != nil {
+		return err
+	}
+	s.observeSize(len(data))
+	if err := s.checkLimits(session, data); err != nil {
+		return err
+	}
+	session.mutex.RLock()
+	id, expiry := []byte(session.data.ID), session.data.Expiry
+	session.mutex.RUnlock()
+
+	value := make([]byte, 8+len(data))
+	binary.BigEndian.PutUint64(value, uint64(expiry))
+	copy(value[8:], data)
+
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		sessions, index := tx.Bucket(boltSessionsBucket), tx.Bucket(boltExpiryBucket)
+		if old := sessions.Get(id); len(old) >= 8 {
+			if err := index.Delete(expiryKey(int64(binary.BigEndian.Uint64(old)), id)); err != nil {
+				return err
+			}
+		}
+		if err := sessions.Put(id, value); err != nil {
+			return err
+		}
+		return index.Put(expiryKey(expiry, id), nil)
+	})
+	if err != nil {
+		return err
+	}
+	s.emit(EventSaved, session)
+	return nil
+}
+
+// Delete removes the session and its entry in the expiry index.
+func (s *BoltStore) Delete(session *Session) error {
+	id := []byte(session.GetID())
+	deleted := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		sessions := tx.Bucket(boltSessionsBucket)
+		old := sessions.Get(id)
+		if old == nil {
+			return nil
+		}
+		deleted = true
+		if len(old) >= 8 {
+			if err := tx.Bucket(boltExpiryBucket).Delete(expiryKey(int64(binary.BigEndian.Uint64(old)), id)); err != nil {
+				return err
+			}
+		}
+		return sessions.Delete(id)
+	})
+	if err != nil {
+		return err
+	}
+	if deleted {
+		s.emit(EventDestroyed, session)
+	}
+	return nil
+}
+
+// load reads the session with the id, it returns nil if the session doesn't exist or has expired.
+func (s *BoltStore) load(id string) (*Session, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltSessionsBucket).Get([]byte(id))
+		if len(value) < 8 || int64(binary.BigEndian.Uint64(value)) <= time.Now().Unix() {
+			return nil
+		}
+		// The value is only valid during the transaction.
+		data = append([]byte(nil), value[8:]...)
+		return nil
+	})
+	if err != nil {
+		s.logLoadError(id, err)
+		return nil, err
+	}
+	if data == nil {
+		return nil, nil
+	}
+
+	session, err := decodeSession(s.serializer, data)
+	if err != nil {
+		s.logDecodeError(id, err)
+		return nil, err
+	}
+	return session, nil
+}
+
+// Range calls fn for every live session in the database until fn returns false.
+// Sessions are read in a single transaction first, fn may call back into the store.
+func (s *BoltStore) Range(ctx context.Context, fn func(session *Session) bool) error {
+	var payloads [][]byte
+	now := time.Now().Unix()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltSessionsBucket).ForEach(func(_, value []byte) error {
+			if len(value) >= 8 && int64(binary.BigEndian.Uint64(value)) > now {
+				payloads = append(payloads, append([]byte(nil), value[8:]...))
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, data := range payloads {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		session, err := decodeSession(s.serializer, data)
+		if err != nil {
+			return err
+		}
+		session.data.IsNew = false
+		if !fn(session) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// generateID generates a unique session ID.
+func (s *BoltStore) generateID() (string, error) {
+	for {
+		id, err := generateRandomID(s.idLength)
+		if err != nil {
+			return "", err
+		}
+		var exists bool
+		_ = s.db.View(func(tx *bolt.Tx) error {
+			exists = tx.Bucket(boltSessionsBucket).Get([]byte(id)) != nil
+			return nil
+		})
+		if !exists {
+			return id, nil
+		}
+		s.logCollision(id)
+	}
+}
+
+// gc periodically deletes expired sessions
+func (s *BoltStore) gc() {
+	ticker := time.NewTicker(s.gcInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.sweep(); err != nil {
+			s.logSweepError(err)
+		}
+	}
+}
+
+// sweep deletes expired sessions found through the expiry index and returns how many were deleted.
+func (s *BoltStore) sweep() (int, error) {
+	start := time.Now()
+	var ids []string
+	now := expiryKey(time.Now().Unix()+1, nil)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		sessions, index := tx.Bucket(boltSessionsBucket), tx.Bucket(boltExpiryBucket)
+		var expired [][]byte
+		c := index.Cursor()
+		for k, _ := c.First(); k != nil && bytes.Compare(k, now) < 0; k, _ = c.Next() {
+			expired = append(expired, append([]byte(nil), k...))
+		}
+		// Buckets must not be modified while iterating them with a cursor.
+		for _, k := range expired {
+			if err := sessions.Delete(k[8:]); err != nil {
+				return err
+			}
+			if err := index.Delete(k); err != nil {
+				return err
+			}
+			ids = append(ids, string(k[8:]))
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	s.observeSweep(start, len(ids))
+	for _, id := range ids {
+		s.emitID(EventExpired, id, "")
+	}
+	return len(ids), nil
+}
+
+// expiryKey returns the key of a session in the expiry index.
+// Expiry is stored with its sign bit flipped, so negative timestamps sort first as well.
+func expiryKey(expiry int64, id []byte) []byte {
+	key := make([]byte, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(expiry)^(1<<63))
+	copy(key[8:], id)
+	return key
+}
@@ -0,0 +1,85 @@
+package sessions
+
+import (
+	"net/http"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	bolt "go.etcd.io/bbolt"
+)
+
+func setupBoltStore(t *testing.T) *BoltStore {
+	db, err := bolt.Open(filepath.Join(t.TempDir(), "sessions.db"), 0o600, nil)
+	assert.NoError(t, err)
+	t.Cleanup(func() { _ = db.Close() })
+
+	store, err := NewBoltStore(db)
+	assert.NoError(t, err)
+	return store.(*BoltStore)
+}
+
+func TestBoltStore_GetSaveDelete(t *testing.T) {
+	store := setupBoltStore(t)
+
+	session, err := store.New("session-key")
+	assert.NoError(t, err)
+	session.SetValue("name", "Coco")
+	session.SetMaxAge(120)
+	assert.NoError(t, store.Save(session))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: session.GetID()})
+	loaded, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.False(t, loaded.IsNew())
+	assert.Equal(t, "Coco", loaded.GetValueByKey("name"))
+
+	assert.NoError(t, store.Delete(loaded))
+	loaded, err = store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.True(t, loaded.IsNew())
+}
+
+func TestBoltStore_Sweep(t *testing.T) {
+	store := setupBoltStore(t)
+
+	expired, _ := store.New("session-key")
+	expired.SetMaxAge(-1)
+	assert.NoError(t, store.Save(expired))
+	live, _ := store.New("session-key")
+	// Saving again moves the session in the expiry index instead of adding a second entry.
+	live.SetMaxAge(120)
+	assert.NoError(t, store.Save(live))
+
+	deleted, err := store.sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	loaded, err := store.load(live.GetID())
+	assert.NoError(t, err)
+	assert.NotNil(t, loaded)
+	_ = store.db.View(func(tx *bolt.Tx) error {
+		assert.Equal(t, 1, tx.Bucket(boltSessionsBucket).Stats().KeyN)
+		assert.Equal(t, 1, tx.Bucket(boltExpiryBucket).Stats().KeyN)
+		return nil
+	})
+}
+
+func TestBoltStore_Fingerprint(t *testing.T) {
+	store := setupBoltStore(t)
+	WithBoltTrustedProxies("10.0.0.0/8")(store)
+	WithBoltFingerprint(FingerprintPolicy{IPv4Prefix: 24, Invalidate: true})(store)
+
+	req := newFingerprintRequest(nil, "10.0.0.1:1234", "firefox")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	session, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7", session.GetIP())
+	cookie := &http.Cookie{Name: "session-key", Value: session.GetID()}
+
+	stolen, err := store.Get(newFingerprintRequest(cookie, "198.51.100.1:1234", "firefox"), "session-key")
+	assert.NoError(t, err)
+	assert.True(t, stolen.IsNew())
+	assert.NotEqual(t, session.GetID(), stolen.GetID())
+}
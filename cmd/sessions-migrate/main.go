@@ -0,0 +1,146 @@
+// Command sessions-migrate copies live sessions from one store to another,
+// keeping their IDs and TTLs, so users don't have to log in again.
+//
+// Stores are given as URLs:
+//
+//	redis://[:password@]host:port/db   RedisStore
+//	file:/path/to/dir                  FileStore
+//	bolt:/path/to/sessions.db          BoltStore
+//	sqlite:/path/to/sessions.db        SQLStore with SQLite
+//	snapshot:/path/to/file             MemoryStore snapshot, see MemoryStore.Snapshot
+//
+// Example:
+//
+//	sessions-migrate -from snapshot:/var/lib/app/sessions.snapshot -to redis://localhost:6379/0
+package main
+
+import (
+	"context"
+	"database/sql"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/shwezhu/sessions"
+	bolt "go.etcd.io/bbolt"
+	_ "modernc.org/sqlite"
+)
+
+func main() {
+	from := flag.String("from", "", "URL of the store to copy sessions from")
+	to := flag.String("to", "", "URL of the store to copy sessions to")
+	timeout := flag.Duration("timeout", 10*time.Minute, "maximum duration of the migration")
+	flag.Parse()
+	if *from == "" || *to == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	src, closeSrc, err := openStore(*from, false)
+	if err != nil {
+		log.Fatalf("failed to open source store: %v", err)
+	}
+	defer closeSrc()
+	dst, closeDst, err := openStore(*to, true)
+	if err != nil {
+		log.Fatalf("failed to open destination store: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	n, err := sessions.Migrate(ctx, src, dst)
+	if err != nil {
+		log.Fatalf("migrated %d sessions before failing: %v", n, err)
+	}
+	if err := closeDst(); err != nil {
+		log.Fatalf("failed to close destination store: %v", err)
+	}
+	fmt.Printf("migrated %d sessions\n", n)
+}
+
+// openStore opens the store at the URL, the returned function releases it.
+// A snapshot destination is written when it's released.
+func openStore(url string, isDst bool) (sessions.Store, func() error, error) {
+	scheme, path, ok := strings.Cut(url, ":")
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid store url: %s", url)
+	}
+	noop := func() error { return nil }
+
+	switch scheme {
+	case "redis", "rediss":
+		opts, err := redis.ParseURL(url)
+		if err != nil {
+			return nil, nil, err
+		}
+		client := redis.NewClient(opts)
+		store, err := sessions.NewRedisStore(client)
+		return store, client.Close, err
+	case "file":
+		store, err := sessions.NewFileStore(path)
+		return store, noop, err
+	case "bolt":
+		db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: time.Second})
+		if err != nil {
+			return nil, nil, err
+		}
+		store, err := sessions.NewBoltStore(db)
+		return store, db.Close, err
+	case "sqlite":
+		db, err := sql.Open("sqlite", path)
+		if err != nil {
+			return nil, nil, err
+		}
+		store, err := sessions.NewSQLStore(db, sessions.DialectSQLite)
+		return store, db.Close, err
+	case "snapshot":
+		store, err := sessions.NewMemoryStore()
+		if err != nil {
+			return nil, nil, err
+		}
+		memoryStore := store.(*sessions.MemoryStore)
+		if !isDst {
+			f, err := os.Open(path)
+			if err != nil {
+				return nil, nil, err
+			}
+			defer f.Close()
+			return store, noop, memoryStore.Restore(f)
+		}
+		return store, func() error {
+			return writeSnapshot(memoryStore, path)
+		}, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported store: %s", scheme)
+	}
+}
+
+// writeSnapshot writes a snapshot of the store into a temp file next to path, then renames it to path,
+// so an existing snapshot is only replaced by a complete one.
+// Snapshots hold session data, the temp file is created readable by its owner only.
+func writeSnapshot(store *sessions.MemoryStore, path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Remove fails harmlessly once the temp file has been renamed.
+	defer os.Remove(tmp.Name())
+
+	if err := store.Snapshot(tmp); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,73 @@
+package sessions
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+)
+
+// compressedHeader is the first byte of payloads compressed by CompressedCodec.
+// JSON never starts with it, so payloads written before compression was enabled are still read.
+const compressedHeader byte = 0x01
+
+// CompressedCodec compresses the payloads of Codec with gzip once they reach Threshold bytes.
+// Smaller payloads are written as is, and payloads without the compression header are read as is,
+// so compressed and uncompressed sessions can coexist while compression is rolled out.
+type CompressedCodec struct {
+	Codec     Codec // codec whose payloads are compressed, the JSON Serializer if nil
+	Threshold int   // size from which payloads are compressed, 0 compresses all payloads
+	Level     int   // gzip compression level, gzip.DefaultCompression if 0
+}
+
+// Compress returns a CompressedCodec compressing the payloads of codec from threshold bytes.
+func Compress(codec Codec, threshold int) *CompressedCodec {
+	return &CompressedCodec{Codec: codec, Threshold: threshold}
+}
+
+func (c *CompressedCodec) Serialize(session *Session) ([]byte, error) {
+	data, err := c.codec().Serialize(session)
+	if err != nil || len(data) < c.Threshold {
+		return data, err
+	}
+
+	level := c.Level
+	if level == 0 {
+		level = gzip.DefaultCompression
+	}
+	var buf bytes.Buffer
+	buf.WriteByte(compressedHeader)
+	w, err := gzip.NewWriterLevel(&buf, level)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (c *CompressedCodec) Deserialize(data []byte, session *Session) error {
+	if len(data) == 0 || data[0] != compressedHeader {
+		return c.codec().Deserialize(data, session)
+	}
+	r, err := gzip.NewReader(bytes.NewReader(data[1:]))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return c.codec().Deserialize(decompressed, session)
+}
+
+func (c *CompressedCodec) codec() Codec {
+	if c.Codec == nil {
+		return &Serializer{}
+	}
+	return c.Codec
+}
@@ -0,0 +1,60 @@
+package sessions
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompressedCodec(t *testing.T) {
+	codec := Compress(nil, 256)
+	session := NewSession("session-key", "id", *defaultOptions())
+
+	// Small payloads are written as is.
+	data, err := codec.Serialize(session)
+	assert.NoError(t, err)
+	assert.Equal(t, byte('{'), data[0])
+
+	session.SetValue("notes", strings.Repeat("session ", 1000))
+	plain, _ := (&Serializer{}).Serialize(session)
+	data, err = codec.Serialize(session)
+	assert.NoError(t, err)
+	assert.Equal(t, compressedHeader, data[0])
+	assert.Less(t, len(data), len(plain)/10)
+
+	decoded := &Session{}
+	assert.NoError(t, codec.Deserialize(data, decoded))
+	assert.Equal(t, session.GetValueByKey("notes"), decoded.GetValueByKey("notes"))
+
+	// Payloads written before compression was enabled are still read.
+	legacy := &Session{}
+	assert.NoError(t, codec.Deserialize(plain, legacy))
+	assert.Equal(t, "id", legacy.GetID())
+
+	assert.ErrorIs(t, codec.Deserialize([]byte{compressedHeader, 'x'}, &Session{}), ErrDecode)
+}
+
+func TestRedisStore_Codec(t *testing.T) {
+	client := setupRedisClient()
+	legacyStore, _ := NewRedisStore(client)
+	store, _ := NewRedisStore(client, WithRedisCodec(Compress(nil, 0)))
+
+	// 压缩前写入的会话仍然可以读取
+	legacy, _ := legacyStore.New("codec_session")
+	legacy.SetValue("name", "Coco")
+	assert.NoError(t, legacyStore.Save(legacy))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "codec_session", Value: legacy.GetID()})
+	session, err := store.Get(req, "codec_session")
+	assert.NoError(t, err)
+	assert.Equal(t, "Coco", session.GetValueByKey("name"))
+
+	// 再次保存后以压缩格式存储
+	assert.NoError(t, store.Save(session))
+	data, _ := client.Get(context.Background(), session.GetID()).Bytes()
+	assert.Equal(t, compressedHeader, data[0])
+}
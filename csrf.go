@@ -0,0 +1,111 @@
+package sessions
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+const (
+	// CSRFHeader is the request header CSRFProtect reads the token from.
+	CSRFHeader = "X-CSRF-Token"
+	// CSRFField is the form field CSRFProtect reads the token from when the header is absent.
+	CSRFField = "csrf_token"
+
+	csrfSecretLength = 32
+)
+
+// CSRFToken returns a CSRF token tied to the session.
+// The secret behind the token is created on first call and kept in the session only,
+// so save the session into its store afterwards: except for the MemoryStore, which holds the session itself,
+// stores keep a copy of the session and reject the token until the secret is saved.
+// Every call returns a differently masked token (BREACH mitigation),
+// all of them are valid as long as the session lives.
+func (s *Session) CSRFToken() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.data.CSRFSecret == nil {
+		secret := make([]byte, csrfSecretLength)
+		if _, err := rand.Read(secret); err != nil {
+			return "", fmt.Errorf("failed to generate csrf secret: %w", err)
+		}
+		s.data.CSRFSecret = secret
+	}
+	return maskCSRFToken(s.data.CSRFSecret)
+}
+
+// verifyCSRFToken reports whether the masked token matches the secret of the session.
+func (s *Session) verifyCSRFToken(token string) bool {
+	s.mutex.RLock()
+	secret := s.data.CSRFSecret
+	s.mutex.RUnlock()
+	if secret == nil {
+		return false
+	}
+	unmasked, ok := unmaskCSRFToken(token)
+	return ok && subtle.ConstantTimeCompare(unmasked, secret) == 1
+}
+
+// maskCSRFToken returns base64(pad || secret XOR pad) with a random one-time pad.
+func maskCSRFToken(secret []byte) (string, error) {
+	token := make([]byte, 2*len(secret))
+	pad := token[:len(secret)]
+	if _, err := rand.Read(pad); err != nil {
+		return "", fmt.Errorf("failed to mask csrf token: %w", err)
+	}
+	for i := range secret {
+		token[len(secret)+i] = secret[i] ^ pad[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(token), nil
+}
+
+func unmaskCSRFToken(token string) ([]byte, bool) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil || len(raw) != 2*csrfSecretLength {
+		return nil, false
+	}
+	pad, masked := raw[:csrfSecretLength], raw[csrfSecretLength:]
+	secret := make([]byte, csrfSecretLength)
+	for i := range secret {
+		secret[i] = masked[i] ^ pad[i]
+	}
+	return secret, true
+}
+
+// CSRFProtect returns a middleware that rejects requests with unsafe methods
+// (anything but GET, HEAD, OPTIONS and TRACE) whose CSRF token, read from CSRFHeader
+// or the CSRFField form field, doesn't match the session named name in store.
+//
+// The session is fetched through the request Registry,
+// so handlers using GetRegistry(r).Get get the same session.
+func CSRFProtect(store Store, name string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.Method {
+			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if _, ok := r.Context().Value(registryKey{}).(*Registry); !ok {
+				r = WithRegistry(r)
+			}
+			session, err := GetRegistry(r).Get(store, name)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			token := r.Header.Get(CSRFHeader)
+			if token == "" {
+				token = r.PostFormValue(CSRFField)
+			}
+			if !session.verifyCSRFToken(token) {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
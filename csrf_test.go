@@ -0,0 +1,92 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSRFProtect(t *testing.T) {
+	store, _ := NewMemoryStore()
+	session, _ := store.New("session-key")
+	token, err := session.CSRFToken()
+	assert.NoError(t, err)
+	other, _ := session.CSRFToken()
+	assert.NotEqual(t, token, other, "tokens should be masked differently")
+
+	handler := CSRFProtect(store, "session-key")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	cookie := &http.Cookie{Name: "session-key", Value: session.GetID()}
+
+	testCases := []struct {
+		method       string
+		header       string
+		form         string
+		expectedCode int
+	}{
+		{method: http.MethodGet, expectedCode: http.StatusNoContent},
+		{method: http.MethodPost, expectedCode: http.StatusForbidden},
+		{method: http.MethodPost, header: token, expectedCode: http.StatusNoContent},
+		{method: http.MethodPut, header: other, expectedCode: http.StatusNoContent},
+		{method: http.MethodPost, form: token, expectedCode: http.StatusNoContent},
+		{method: http.MethodDelete, header: "forged", expectedCode: http.StatusForbidden},
+	}
+	for i, tc := range testCases {
+		var req *http.Request
+		if tc.form != "" {
+			body := url.Values{CSRFField: {tc.form}}.Encode()
+			req = httptest.NewRequest(tc.method, "http://example.com", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			req = httptest.NewRequest(tc.method, "http://example.com", nil)
+		}
+		if tc.header != "" {
+			req.Header.Set(CSRFHeader, tc.header)
+		}
+		req.AddCookie(cookie)
+		rsp := httptest.NewRecorder()
+		handler.ServeHTTP(rsp, req)
+		assert.Equal(t, tc.expectedCode, rsp.Code, "test case: %d", i)
+	}
+
+	// A token of another session is rejected.
+	stranger, _ := store.New("session-key")
+	strangerToken, _ := stranger.CSRFToken()
+	req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+	req.Header.Set(CSRFHeader, strangerToken)
+	req.AddCookie(cookie)
+	rsp := httptest.NewRecorder()
+	handler.ServeHTTP(rsp, req)
+	assert.Equal(t, http.StatusForbidden, rsp.Code)
+}
+
+func TestCSRFProtect_RedisStore(t *testing.T) {
+	client := setupRedisClient()
+	store, _ := NewRedisStore(client)
+	session, _ := store.New("csrf_session")
+	defer store.Delete(session)
+	token, err := session.CSRFToken()
+	assert.NoError(t, err)
+
+	handler := CSRFProtect(store, "csrf_session")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	post := func() int {
+		req := httptest.NewRequest(http.MethodPost, "http://example.com", nil)
+		req.Header.Set(CSRFHeader, token)
+		req.AddCookie(&http.Cookie{Name: "csrf_session", Value: session.GetID()})
+		rsp := httptest.NewRecorder()
+		handler.ServeHTTP(rsp, req)
+		return rsp.Code
+	}
+
+	// Redis holds a copy of the session, which has no secret until the session is saved.
+	assert.Equal(t, http.StatusForbidden, post())
+	assert.NoError(t, store.Save(session))
+	assert.Equal(t, http.StatusNoContent, post())
+}
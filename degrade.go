@@ -0,0 +1,219 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// FailurePolicy decides what a RedisStore does when Redis is unavailable.
+type FailurePolicy int
+
+const (
+	// FailClosed returns errors wrapping ErrStoreUnavailable, it's the default.
+	FailClosed FailurePolicy = iota
+	// FailEphemeral hands out new sessions which aren't stored and drops saves,
+	// so requests are still served, without their session state, until Redis recovers.
+	FailEphemeral
+	// FailLocal keeps sessions in a local MemoryStore until Redis recovers,
+	// they are moved to Redis the next time they are saved after it recovers.
+	// The local store isn't shared between instances, so it works best with sticky sessions.
+	FailLocal
+)
+
+func (p FailurePolicy) String() string {
+	switch p {
+	case FailClosed:
+		return "fail-closed"
+	case FailEphemeral:
+		return "ephemeral"
+	case FailLocal:
+		return "local"
+	default:
+		return fmt.Sprintf("FailurePolicy(%d)", int(p))
+	}
+}
+
+// CorruptPolicy decides what a RedisStore does with a session whose stored data can't be decoded,
+// e.g. it was written by an incompatible version of the application.
+type CorruptPolicy int
+
+const (
+	// CorruptFail makes Get return an error wrapping ErrDecode until the session expires, it's the default.
+	CorruptFail CorruptPolicy = iota
+	// CorruptReplace makes Get delete the corrupt session and issue a new one.
+	CorruptReplace
+)
+
+// DegradationMetrics is implemented by Metrics which report whether a store runs in degraded mode,
+// i.e. its backend is unavailable and sessions are served according to its failure policy.
+type DegradationMetrics interface {
+	SetDegraded(degraded bool)
+}
+
+// redisHealth tracks whether Redis is available.
+// After threshold consecutive failures it opens a circuit breaker, so calls fail fast
+// instead of waiting on a dead server. Once cooldown has passed calls are let through again
+// to probe Redis, and the next failure opens the breaker again.
+type redisHealth struct {
+	threshold int // 0 disables the circuit breaker
+	cooldown  time.Duration
+
+	mutex    sync.Mutex
+	failures int       // consecutive failures
+	openedAt time.Time // zero while the breaker is closed
+	degraded bool
+}
+
+// allow reports whether a call may be sent to Redis.
+func (h *redisHealth) allow() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.openedAt.IsZero() || time.Since(h.openedAt) >= h.cooldown
+}
+
+// record records whether a call failed because Redis is unavailable,
+// and returns whether the store entered or left degraded mode.
+func (h *redisHealth) record(failed bool) (changed bool) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if !failed {
+		h.failures = 0
+		h.openedAt = time.Time{}
+		changed, h.degraded = h.degraded, false
+		return changed
+	}
+	h.failures++
+	if h.threshold > 0 && h.failures >= h.threshold {
+		h.openedAt = time.Now()
+	}
+	changed, h.degraded = !h.degraded, true
+	return changed
+}
+
+func (h *redisHealth) isDegraded() bool {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.degraded
+}
+
+// guard calls fn unless the circuit breaker is open, and records whether Redis was available.
+func (s *RedisStore) guard(fn func() error) error {
+	if !s.health.allow() {
+		return ErrCircuitOpen
+	}
+	err := fn()
+	// Other errors, e.g. ErrDecode or a WRONGTYPE reply, mean Redis did answer.
+	if s.health.record(errors.Is(err, ErrStoreUnavailable)) {
+		s.reportDegraded(s.health.isDegraded(), err)
+	}
+	return err
+}
+
+// redisError wraps err with ErrStoreUnavailable if it means Redis couldn't be reached,
+// i.e. a network error, a timeout or an exhausted connection pool.
+// Replies of the server, e.g. WRONGTYPE, are returned unchanged, Redis did answer.
+func redisError(err error) error {
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, ErrStoreUnavailable):
+		return err
+	case errors.As(err, &netErr), errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, redis.ErrClosed), errors.Is(err, context.DeadlineExceeded),
+		strings.HasPrefix(err.Error(), "redis: connection pool"):
+		return unavailable(err)
+	default:
+		return err
+	}
+}
+
+// reportDegraded logs that the store entered or left degraded mode, and reports it to metrics.
+func (s *RedisStore) reportDegraded(degraded bool, err error) {
+	if degraded {
+		s.logger.Warn("sessions: redis unavailable, running degraded",
+			slog.String("policy", s.failurePolicy.String()), slog.Any("error", err))
+	} else {
+		s.logger.Info("sessions: redis available again")
+	}
+	if metrics := s.metrics.Load(); metrics != nil {
+		if m, ok := (*metrics).(DegradationMetrics); ok {
+			m.SetDegraded(degraded)
+		}
+	}
+}
+
+// degradedGet serves Get according to the failure policy while Redis is unavailable.
+func (s *RedisStore) degradedGet(r *http.Request, name string) (*Session, error) {
+	if s.failurePolicy == FailLocal {
+		return s.fallback.Get(r, name)
+	}
+	return s.ephemeral(r, name)
+}
+
+// degradedNew serves New according to the failure policy while Redis is unavailable.
+func (s *RedisStore) degradedNew(name string) (*Session, error) {
+	if s.failurePolicy == FailLocal {
+		return s.fallback.New(name)
+	}
+	return s.ephemeral(nil, name)
+}
+
+// degradedSave serves Save according to the failure policy after Redis failed with err.
+func (s *RedisStore) degradedSave(session *Session, err error) error {
+	if s.failurePolicy == FailLocal {
+		return s.fallback.Save(session)
+	}
+	s.logger.Warn("sessions: redis unavailable, session not saved",
+		sessionAttr(session.GetID()), slog.Any("error", err))
+	return nil
+}
+
+// ephemeral returns a new session which isn't stored anywhere.
+func (s *RedisStore) ephemeral(r *http.Request, name string) (*Session, error) {
+	id, err := generateRandomID(s.idLength)
+	if err != nil {
+		return nil, err
+	}
+	session := NewSession(name, id, *s.options)
+	s.touch(session, r)
+	return session, nil
+}
+
+// newFallback creates the local store used by FailLocal, configured like the RedisStore.
+func (s *RedisStore) newFallback() *MemoryStore {
+	store, _ := NewMemoryStore(WithOptions(s.options), WithSessionIDLength(s.idLength), WithLogger(s.logger),
+		func(store *MemoryStore) {
+			store.trustedProxies = s.trustedProxies
+			store.fingerprint = s.fingerprint
+		})
+	return store.(*MemoryStore)
+}
+
+// fromFallback returns the session with the id if it was kept in the local store while Redis was unavailable,
+// or nil.
+func (s *RedisStore) fromFallback(id string) *Session {
+	if s.fallback == nil {
+		return nil
+	}
+	session, err := s.fallback.Load(id)
+	if err != nil {
+		return nil
+	}
+	return session
+}
+
+// forget removes the session from the local store, once it's in Redis or deleted.
+func (s *RedisStore) forget(session *Session) {
+	if s.fallback != nil {
+		_ = s.fallback.Delete(session)
+	}
+}
@@ -0,0 +1,161 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+// flakyRedis is a Redis client whose server can be taken down and brought back up.
+type flakyRedis struct {
+	down  atomic.Bool
+	dials atomic.Int32
+}
+
+func (f *flakyRedis) client() *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:       "localhost:6379",
+		MaxRetries: -1,
+		Dialer: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			f.dials.Add(1)
+			if f.down.Load() {
+				return nil, &net.OpError{Op: "dial", Net: network, Err: errors.New("connection refused")}
+			}
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	})
+}
+
+type degradedMetrics struct {
+	fakeMetrics
+	degraded atomic.Bool
+}
+
+func (m *degradedMetrics) SetDegraded(degraded bool) { m.degraded.Store(degraded) }
+
+func TestRedisStore_FailClosed(t *testing.T) {
+	flaky := &flakyRedis{}
+	flaky.down.Store(true)
+	store, _ := NewRedisStore(flaky.client())
+
+	_, err := store.New("degraded_session")
+	assert.ErrorIs(t, err, ErrStoreUnavailable)
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	_, err = store.Get(req, "degraded_session")
+	assert.ErrorIs(t, err, ErrStoreUnavailable)
+}
+
+func TestRedisStore_FailEphemeral(t *testing.T) {
+	flaky := &flakyRedis{}
+	flaky.down.Store(true)
+	store, _ := NewRedisStore(flaky.client(), WithRedisFailurePolicy(FailEphemeral))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "degraded_session", Value: "unknown"})
+	session, err := store.Get(req, "degraded_session")
+	assert.NoError(t, err)
+	assert.True(t, session.IsNew())
+	assert.NoError(t, store.Save(session), "saves are dropped")
+	assert.ErrorIs(t, store.Delete(session), ErrStoreUnavailable, "deletes must not look successful")
+}
+
+func TestRedisStore_FailLocal(t *testing.T) {
+	flaky := &flakyRedis{}
+	flaky.down.Store(true)
+	client := flaky.client()
+	store, _ := NewRedisStore(client, WithRedisFailurePolicy(FailLocal))
+
+	session, err := store.New("degraded_session")
+	assert.NoError(t, err)
+	session.SetValue("name", "Coco")
+	assert.NoError(t, store.Save(session))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "degraded_session", Value: session.GetID()})
+	loaded, err := store.Get(req, "degraded_session")
+	assert.NoError(t, err)
+	assert.False(t, loaded.IsNew())
+	assert.Equal(t, "Coco", loaded.GetValueByKey("name"))
+
+	// Once Redis recovers, the session is still found and moves to Redis when saved.
+	flaky.down.Store(false)
+	loaded, err = store.Get(req, "degraded_session")
+	assert.NoError(t, err)
+	assert.Equal(t, "Coco", loaded.GetValueByKey("name"))
+	assert.NoError(t, store.Save(loaded))
+	exists, _ := client.Exists(context.Background(), session.GetID()).Result()
+	assert.Equal(t, int64(1), exists)
+	_, err = store.(*RedisStore).fallback.Load(session.GetID())
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestRedisStore_CircuitBreaker(t *testing.T) {
+	flaky := &flakyRedis{}
+	flaky.down.Store(true)
+	store, _ := NewRedisStore(flaky.client(), WithRedisCircuitBreaker(2, 50*time.Millisecond))
+	metrics := &degradedMetrics{}
+	Instrument(store, metrics, nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := store.New("breaker_session")
+		assert.ErrorIs(t, err, ErrStoreUnavailable)
+	}
+	assert.True(t, metrics.degraded.Load())
+
+	// The breaker is open, Redis isn't called.
+	dials := flaky.dials.Load()
+	_, err := store.New("breaker_session")
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.Equal(t, dials, flaky.dials.Load())
+
+	flaky.down.Store(false)
+	time.Sleep(60 * time.Millisecond)
+	_, err = store.New("breaker_session")
+	assert.NoError(t, err)
+	assert.False(t, metrics.degraded.Load())
+}
+
+func TestRedisStore_ReplyErrorsDontOpenBreaker(t *testing.T) {
+	client := setupRedisClient()
+	store, _ := NewRedisStore(client, WithRedisKeyPrefix("reply:"),
+		WithRedisFailurePolicy(FailEphemeral), WithRedisCircuitBreaker(1, time.Minute))
+	metrics := &degradedMetrics{}
+	Instrument(store, metrics, nil)
+
+	// A forged cookie naming a hash makes GET fail with WRONGTYPE, Redis did answer.
+	id := "replyerrorhash01"
+	client.HSet(context.Background(), "reply:"+id, "field", "value")
+	defer client.Del(context.Background(), "reply:"+id)
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "reply_session", Value: id})
+	for i := 0; i < 2; i++ {
+		_, err := store.Get(req, "reply_session")
+		assert.Error(t, err)
+		assert.NotErrorIs(t, err, ErrStoreUnavailable)
+	}
+	assert.False(t, metrics.degraded.Load())
+
+	session, err := store.New("reply_session")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Delete(session))
+}
+
+func TestTieredStore_FailEphemeral(t *testing.T) {
+	client := setupRedisClient()
+	remote, _ := NewRedisStore(client, WithRedisFailurePolicy(FailEphemeral))
+	store, err := NewTieredStore(remote.(*RedisStore))
+	assert.NoError(t, err)
+	session, _ := store.New("degraded_session")
+
+	// Saves are dropped by the RedisStore, failing to publish them mustn't fail them.
+	_ = client.Close()
+	session.SetValue("name", "Coco")
+	assert.NoError(t, store.Save(session))
+}
@@ -0,0 +1,154 @@
+package sessions
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// encryptedHeader is the first byte of payloads encrypted by EncryptedCodec.
+const encryptedHeader byte = 0x02
+
+// dataKeySize is the size of the AES-256 key generated for every payload.
+const dataKeySize = 32
+
+// EncryptedCodec encrypts the payloads of a Codec with envelope encryption:
+// every payload is encrypted with a new AES-256-GCM data key, which is encrypted
+// with the current key encryption key. Payloads name the key encrypting their data key,
+// so keys can be rotated: add a new current key and keep the old ones until every session
+// has been saved again, or has expired. Sessions are encrypted with the current key when they are saved.
+//
+// Payload layout: header, key ID length, key ID, encrypted data key, encrypted data.
+// Encrypted values are prefixed with their GCM nonce.
+type EncryptedCodec struct {
+	codec   Codec
+	current string
+	keys    map[string]cipher.AEAD
+
+	// AllowPlaintext reads payloads written before encryption was enabled, while it's rolled out.
+	AllowPlaintext bool
+}
+
+// Encrypt returns an EncryptedCodec encrypting the payloads of codec, the JSON Serializer if nil.
+// keys maps key IDs to AES keys of 16, 24 or 32 bytes, and current is the ID of the key encrypting new payloads.
+// To compress sessions as well, encrypt a CompressedCodec: encrypted payloads don't compress.
+func Encrypt(codec Codec, current string, keys map[string][]byte) (*EncryptedCodec, error) {
+	if codec == nil {
+		codec = &Serializer{}
+	}
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current key %q not found", current)
+	}
+	c := &EncryptedCodec{codec: codec, current: current, keys: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if id == "" || len(id) > 255 {
+			return nil, fmt.Errorf("key ID %q must be 1 to 255 bytes long", id)
+		}
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key %q: %w", id, err)
+		}
+		c.keys[id] = aead
+	}
+	return c, nil
+}
+
+func (c *EncryptedCodec) Serialize(session *Session) ([]byte, error) {
+	data, err := c.codec.Serialize(session)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	dataAEAD, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte(encryptedHeader)
+	buf.WriteByte(byte(len(c.current)))
+	buf.WriteString(c.current)
+	// The header is authenticated as additional data, so the key ID can't be tampered with.
+	header := bytes.Clone(buf.Bytes())
+	wrappedKey, err := seal(c.keys[c.current], dataKey, header)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(wrappedKey)
+	ciphertext, err := seal(dataAEAD, data, header)
+	if err != nil {
+		return nil, err
+	}
+	buf.Write(ciphertext)
+	return buf.Bytes(), nil
+}
+
+func (c *EncryptedCodec) Deserialize(data []byte, session *Session) error {
+	if len(data) == 0 || data[0] != encryptedHeader {
+		if c.AllowPlaintext {
+			return c.codec.Deserialize(data, session)
+		}
+		return fmt.Errorf("%w: session isn't encrypted", ErrDecode)
+	}
+	if len(data) < 2 || len(data) < 2+int(data[1]) {
+		return fmt.Errorf("%w: truncated encrypted session", ErrDecode)
+	}
+	header, id := data[:2+int(data[1])], string(data[2:2+int(data[1])])
+	keyAEAD, ok := c.keys[id]
+	if !ok {
+		return fmt.Errorf("%w: unknown encryption key %q", ErrDecode, id)
+	}
+
+	rest := data[len(header):]
+	wrappedSize := keyAEAD.NonceSize() + dataKeySize + keyAEAD.Overhead()
+	if len(rest) < wrappedSize {
+		return fmt.Errorf("%w: truncated encrypted session", ErrDecode)
+	}
+	dataKey, err := open(keyAEAD, rest[:wrappedSize], header)
+	if err != nil {
+		return fmt.Errorf("%w: failed to decrypt data key: %w", ErrDecode, err)
+	}
+	dataAEAD, err := newGCM(dataKey)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	plaintext, err := open(dataAEAD, rest[wrappedSize:], header)
+	if err != nil {
+		return fmt.Errorf("%w: failed to decrypt session: %w", ErrDecode, err)
+	}
+	return c.codec.Deserialize(plaintext, session)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts plaintext with a random nonce and returns the nonce followed by the ciphertext.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a value sealed by seal.
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("ciphertext too short")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
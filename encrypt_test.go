@@ -0,0 +1,71 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptedCodec(t *testing.T) {
+	oldKey, newKey := bytes.Repeat([]byte{1}, 32), bytes.Repeat([]byte{2}, 16)
+	codec, err := Encrypt(nil, "2023", map[string][]byte{"2023": oldKey})
+	assert.NoError(t, err)
+
+	session := NewSession("session-key", "id", *defaultOptions())
+	session.SetValue("email", "coco@example.com")
+	data, err := codec.Serialize(session)
+	assert.NoError(t, err)
+	assert.Equal(t, encryptedHeader, data[0])
+	assert.NotContains(t, string(data), "coco@example.com")
+
+	// After rotation, sessions encrypted with the old key are still read,
+	// and they are encrypted with the new key when saved again.
+	rotated, err := Encrypt(nil, "2024", map[string][]byte{"2023": oldKey, "2024": newKey})
+	assert.NoError(t, err)
+	decoded := &Session{}
+	assert.NoError(t, rotated.Deserialize(data, decoded))
+	assert.Equal(t, "coco@example.com", decoded.GetValueByKey("email"))
+	data, _ = rotated.Serialize(decoded)
+	assert.Equal(t, "2024", string(data[2:2+data[1]]))
+
+	// Tampered payloads and unknown keys are rejected.
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)-1] ^= 1
+	assert.ErrorIs(t, rotated.Deserialize(tampered, &Session{}), ErrDecode)
+	assert.ErrorIs(t, codec.Deserialize(data, &Session{}), ErrDecode)
+
+	// Plaintext sessions are only read while rolling out encryption.
+	plain, _ := (&Serializer{}).Serialize(session)
+	assert.ErrorIs(t, codec.Deserialize(plain, &Session{}), ErrDecode)
+	codec.AllowPlaintext = true
+	assert.NoError(t, codec.Deserialize(plain, &Session{}))
+
+	_, err = Encrypt(nil, "missing", map[string][]byte{"2023": oldKey})
+	assert.Error(t, err)
+	_, err = Encrypt(nil, "short", map[string][]byte{"short": []byte("key")})
+	assert.Error(t, err)
+}
+
+func TestRedisStore_EncryptedCodec(t *testing.T) {
+	client := setupRedisClient()
+	codec, _ := Encrypt(Compress(nil, 0), "k1", map[string][]byte{"k1": bytes.Repeat([]byte{7}, 32)})
+	store, _ := NewRedisStore(client, WithRedisCodec(codec))
+
+	session, _ := store.New("encrypted_session")
+	session.SetValue("token", "secret-value")
+	assert.NoError(t, store.Save(session))
+
+	// Redis 中只保存密文
+	data, _ := client.Get(context.Background(), session.GetID()).Bytes()
+	assert.Equal(t, encryptedHeader, data[0])
+	assert.NotContains(t, string(data), "secret-value")
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "encrypted_session", Value: session.GetID()})
+	loaded, err := store.Get(req, "encrypted_session")
+	assert.NoError(t, err)
+	assert.Equal(t, "secret-value", loaded.GetValueByKey("token"))
+}
@@ -0,0 +1,47 @@
+package sessions
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the stores and the serializer of this package, test them with errors.Is.
+// Errors of a store backend, e.g. Redis, are wrapped so the original error can be inspected as well.
+var (
+	// ErrInvalidCookieName is returned by Store.Get when the cookie name contains invalid characters.
+	ErrInvalidCookieName = errors.New("sessions: invalid character in cookie name")
+
+	// ErrInvalidSessionID is returned when a session ID contains invalid characters.
+	ErrInvalidSessionID = errors.New("sessions: invalid session id")
+
+	// ErrInvalidUserID is returned by UserIndex.BindUser when the user ID is empty.
+	ErrInvalidUserID = errors.New("sessions: user id cannot be empty")
+
+	// ErrNotFound is returned when there is no session with the requested ID.
+	ErrNotFound = errors.New("sessions: session not found")
+
+	// ErrExpired is returned when the session with the requested ID has expired
+	// but hasn't been removed from the store yet. It wraps ErrNotFound.
+	ErrExpired = fmt.Errorf("%w: session expired", ErrNotFound)
+
+	// ErrDecode is returned when stored session data can't be deserialized, e.g. it's corrupt.
+	ErrDecode = errors.New("sessions: failed to decode session")
+
+	// ErrStoreUnavailable is returned when the backend of a store, e.g. Redis, fails or can't be reached.
+	ErrStoreUnavailable = errors.New("sessions: store unavailable")
+
+	// ErrCircuitOpen is returned by a RedisStore while its circuit breaker is open. It wraps ErrStoreUnavailable.
+	ErrCircuitOpen = fmt.Errorf("%w: circuit breaker open", ErrStoreUnavailable)
+
+	// ErrSessionHijackSuspected is returned by Store.Get when the fingerprint of the client
+	// doesn't match the one the session was bound to at creation.
+	ErrSessionHijackSuspected = errors.New("sessions: session hijack suspected")
+)
+
+// unavailable wraps an error of a store backend with ErrStoreUnavailable, it returns nil if err is nil.
+func unavailable(err error) error {
+	if err == nil {
+		return nil
+	}
+	return fmt.Errorf("%w: %w", ErrStoreUnavailable, err)
+}
@@ -0,0 +1,50 @@
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors_MemoryStore(t *testing.T) {
+	store, _ := NewMemoryStore()
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	_, err := store.Get(req, "invalid name")
+	assert.ErrorIs(t, err, ErrInvalidCookieName)
+	assert.EqualError(t, err, "sessions: invalid character in cookie name: invalid name")
+
+	session, _ := store.New("session-key")
+	session.data.Expiry = time.Now().Unix() - 1
+	_, err = store.(Inspector).Load(session.GetID())
+	assert.ErrorIs(t, err, ErrExpired)
+	assert.ErrorIs(t, err, ErrNotFound, "expired sessions are not found either")
+
+	assert.ErrorIs(t, store.(UserIndex).BindUser(session, ""), ErrInvalidUserID)
+}
+
+func TestErrors_Serializer(t *testing.T) {
+	err := (&Serializer{}).Deserialize([]byte("{"), &Session{})
+	assert.ErrorIs(t, err, ErrDecode)
+	var syntaxErr *json.SyntaxError
+	assert.True(t, errors.As(err, &syntaxErr), "the json error is wrapped as well")
+}
+
+func TestErrors_RedisStoreUnavailable(t *testing.T) {
+	// Nothing listens on port 1.
+	client := redis.NewClient(&redis.Options{Addr: "localhost:1", MaxRetries: -1})
+	store, _ := NewRedisStore(client)
+
+	_, err := store.New("unavailable_session")
+	assert.ErrorIs(t, err, ErrStoreUnavailable)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "unavailable_session", Value: "id"})
+	_, err = store.Get(req, "unavailable_session")
+	assert.ErrorIs(t, err, ErrStoreUnavailable)
+	assert.NotErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,105 @@
+package sessions
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// EventType is the type of session lifecycle event.
+type EventType int
+
+const (
+	// EventCreated is emitted when a new session is created by New or Get.
+	EventCreated EventType = iota + 1
+	// EventLoaded is emitted when Get finds the session of the request cookie.
+	EventLoaded
+	// EventMissed is emitted when Get is sent a cookie without a live session,
+	// it's followed by EventCreated for the session replacing it.
+	EventMissed
+	// EventSaved is emitted when a session is saved into the store.
+	EventSaved
+	// EventDestroyed is emitted when a stored session is deleted, e.g. by Delete or RevokeUser.
+	// Deleting a session which isn't stored, e.g. after it expired, doesn't emit it.
+	EventDestroyed
+	// EventExpired is emitted when an expired session is removed from the store.
+	EventExpired
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventCreated:
+		return "created"
+	case EventLoaded:
+		return "loaded"
+	case EventMissed:
+		return "missed"
+	case EventSaved:
+		return "saved"
+	case EventDestroyed:
+		return "destroyed"
+	case EventExpired:
+		return "expired"
+	default:
+		return fmt.Sprintf("EventType(%d)", int(t))
+	}
+}
+
+// Event describes something that happened to a session.
+type Event struct {
+	Type      EventType
+	SessionID string
+	Name      string // cookie name of the session, empty if unknown, e.g. for sessions expired in Redis
+	Time      time.Time
+}
+
+// Listener receives session lifecycle events.
+// Listeners are called synchronously by the store, so they should return quickly.
+type Listener func(event Event)
+
+// EventSource is implemented by stores that emit session lifecycle events.
+type EventSource interface {
+	// AddListener registers a listener for all events emitted by the store.
+	AddListener(listener Listener)
+}
+
+// AddListener registers a listener for all events emitted by the store.
+func (b *baseStore) AddListener(listener Listener) {
+	b.listenersMutex.Lock()
+	defer b.listenersMutex.Unlock()
+	b.listeners = append(b.listeners, listener)
+}
+
+// emit sends an event about the session to all listeners.
+// It must not be called while holding a store lock, listeners may call back into the store.
+func (b *baseStore) emit(t EventType, session *Session) {
+	if !b.hasListeners() && !b.logger.Enabled(context.Background(), slog.LevelDebug) {
+		return
+	}
+	session.mutex.RLock()
+	id, name := session.data.ID, session.data.Name
+	session.mutex.RUnlock()
+	b.emitID(t, id, name)
+}
+
+// emitID sends an event about the session with the id to all listeners.
+// Events are logged at debug level as well.
+func (b *baseStore) emitID(t EventType, id, name string) {
+	b.logger.Debug("sessions: session "+t.String(), sessionAttr(id), slog.String("name", name))
+
+	b.listenersMutex.RLock()
+	listeners := b.listeners
+	b.listenersMutex.RUnlock()
+
+	event := Event{Type: t, SessionID: id, Name: name, Time: time.Now()}
+	for _, listener := range listeners {
+		listener(event)
+	}
+}
+
+func (b *baseStore) hasListeners() bool {
+	b.listenersMutex.RLock()
+	defer b.listenersMutex.RUnlock()
+	return len(b.listeners) > 0
+}
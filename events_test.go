@@ -0,0 +1,95 @@
+package sessions
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// eventRecorder collects the events of a store for assertions.
+type eventRecorder struct {
+	mutex  sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) listen(event Event) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.events = append(r.events, event)
+}
+
+func (r *eventRecorder) types() []EventType {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	types := make([]EventType, len(r.events))
+	for i, event := range r.events {
+		types[i] = event.Type
+	}
+	return types
+}
+
+func (r *eventRecorder) count(t EventType) int {
+	n := 0
+	for _, event := range r.types() {
+		if event == t {
+			n++
+		}
+	}
+	return n
+}
+
+func TestMemoryStore_Events(t *testing.T) {
+	store, _ := NewMemoryStore(WithGCInterval(50 * time.Millisecond))
+	recorder := &eventRecorder{}
+	store.(EventSource).AddListener(recorder.listen)
+
+	session, _ := store.New("session-key")
+	assert.NoError(t, store.Save(session))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: session.GetID()})
+	_, _ = store.Get(req, "session-key")
+	assert.NoError(t, store.Delete(session))
+	// The session has been deleted, so the cookie misses and a new session is created.
+	_, _ = store.Get(req, "session-key")
+
+	assert.Equal(t, []EventType{EventCreated, EventSaved, EventLoaded, EventDestroyed, EventMissed, EventCreated},
+		recorder.types())
+	recorder.mutex.Lock()
+	first := recorder.events[0]
+	recorder.mutex.Unlock()
+	assert.Equal(t, session.GetID(), first.SessionID)
+	assert.Equal(t, "session-key", first.Name)
+
+	expired, _ := store.New("session-key")
+	expired.SetMaxAge(-1)
+	assert.Eventually(t, func() bool {
+		types := recorder.types()
+		return types[len(types)-1] == EventExpired
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestEventType_String(t *testing.T) {
+	assert.Equal(t, "destroyed", EventDestroyed.String())
+	assert.Equal(t, "EventType(42)", EventType(42).String())
+}
+
+func TestStores_DestroyedOnlyWhenRemoved(t *testing.T) {
+	memoryStore, _ := NewMemoryStore(WithGCInterval(time.Hour))
+	fileStore, _ := NewFileStore(t.TempDir(), WithFileGCInterval(time.Hour))
+	for name, store := range map[string]Store{"memory": memoryStore, "file": fileStore} {
+		t.Run(name, func(t *testing.T) {
+			recorder := &eventRecorder{}
+			store.(EventSource).AddListener(recorder.listen)
+
+			session, _ := store.New("session-key")
+			assert.NoError(t, store.Delete(session))
+			// The session is already gone, e.g. it has expired and gc removed it.
+			assert.NoError(t, store.Delete(session))
+			assert.Equal(t, 1, recorder.count(EventDestroyed))
+		})
+	}
+}
@@ -0,0 +1,417 @@
+package sessions
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sessionFileExt is the extension of the files FileStore writes sessions into.
+const sessionFileExt = ".session"
+
+// maxFileIDLength is the length of the longest session ID whose hex encoded file name
+// fits in the 255 bytes most filesystems allow.
+const maxFileIDLength = (255 - len(sessionFileExt)) / 2
+
+// FileStore represents a session store which writes one file per session into a directory,
+// sessions survive restarts without an external database.
+//
+// Files are replaced atomically (temp file + rename), so readers never see a partial session.
+// Writers are serialized by a lock file in the directory, which also covers
+// several processes sharing the same directory on platforms that support flock.
+type FileStore struct {
+	*baseStore
+	dir        string
+	serializer Codec
+	gcInterval time.Duration
+	mutex      sync.Mutex // serializes writers within the process
+	lockFile   *os.File   // serializes writers across processes
+}
+
+// NewFileStore creates a FileStore writing sessions into dir, the directory is created if necessary.
+func NewFileStore(dir string, options ...func(store *FileStore)) (Store, error) {
+	base, err := newBaseStore(defaultOptions(), 16)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	lockFile, err := os.OpenFile(filepath.Join(dir, ".lock"), os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open lock file: %w", err)
+	}
+
+	store := &FileStore{
+		baseStore:  base,
+		dir:        dir,
+		serializer: &Serializer{},
+		gcInterval: time.Minute,
+		lockFile:   lockFile,
+	}
+
+	for _, op := range options {
+		op(store)
+	}
+
+	go store.gc()
+
+	return store, nil
+}
+
+// Option functions for customizing FileStore
+
+// WithFileOptions sets the cookie options for the store
+func WithFileOptions(options *Options) func(store *FileStore) {
+	return func(store *FileStore) {
+		if options == nil {
+			return
+		}
+		if err := options.Validate(); err != nil {
+			panic(err)
+		}
+		store.options = options
+	}
+}
+
+// WithFileSessionIDLength sets the session ID length, at most 123 so file names stay short enough
+func WithFileSessionIDLength(length int) func(store *FileStore) {
+	return func(store *FileStore) {
+		if length <= 0 {
+			panic("session ID length must be greater than 0")
+		}
+		if length > maxFileIDLength {
+			panic(fmt.Sprintf("session ID length must be at most %d", maxFileIDLength))
+		}
+		store.idLength = length
+	}
+}
+
+// WithFileGCInterval sets the interval of sweeping expired session files
+func WithFileGCInterval(interval time.Duration) func(store *FileStore) {
+	return func(store *FileStore) {
+		if interval <= 0 {
+			panic("GC interval must be greater than 0")
+		}
+		store.gcInterval = interval
+	}
+}
+
+// WithFileCodec sets the codec of session files, the JSON Serializer by default.
+func WithFileCodec(codec Codec) func(store *FileStore) {
+	return func(store *FileStore) {
+		if codec == nil {
+			panic("codec cannot be nil")
+		}
+		store.serializer = codec
+	}
+}
+
+// WithFileLimits limits the size of sessions, see WithLimits. MaxSize applies to the session files.
+func WithFileLimits(limits Limits) func(store *FileStore) {
+	return func(store *FileStore) {
+		store.limits = validateLimits(limits)
+	}
+}
+
+// WithFileTrustedProxies sets the proxies whose X-Forwarded-For header is trusted
+// when capturing the client IP of a session.
+func WithFileTrustedProxies(proxies ...string) func(store *FileStore) {
+	return func(store *FileStore) {
+		networks, err := parseCIDRs(proxies)
+		if err != nil {
+			panic(err)
+		}
+		store.trustedProxies = networks
+	}
+}
+
+// WithFileFingerprint binds every new session to a fingerprint of its client,
+// see FingerprintPolicy.
+func WithFileFingerprint(policy FingerprintPolicy) func(store *FileStore) {
+	return func(store *FileStore) {
+		store.fingerprint = &policy
+	}
+}
+
+// WithFileLogger sets the logger of the store, see WithLogger.
+func WithFileLogger(logger *slog.Logger) func(store *FileStore) {
+	return func(store *FileStore) {
+		store.setLogger(logger)
+	}
+}
+
+// Get returns the session stored in the file named after the cookie value,
+// if there is no such file or the session has expired, a new session is created.
+func (s *FileStore) Get(r *http.Request, name string) (*Session, error) {
+	return s.getSession(s, r, name)
+}
+
+// New creates a new session and writes it into a file.
+func (s *FileStore) New(name string) (*Session, error) {
+	return s.createSession(s, nil, name)
+}
+
+// Save atomically replaces the file of the session.
+func (s *FileStore) Save(session *Session) error {
+	path, err := s.path(session.GetID())
+	if err != nil {
+		return err
+	}
+	data, err := s.serializer.Serialize(session)
+	if err != nil {
+		return err
+	}
+	s.observeSize(len(data))
+	if err := s.checkLimits(session, data); err != nil {
+		return err
+	}
+
+	if err := s.lock(); err != nil {
+		return err
+	}
+	err = writeFileAtomic(s.dir, path, data)
+	s.unlock()
+	if err != nil {
+		return pathless(err)
+	}
+	s.emit(EventSaved, session)
+	return nil
+}
+
+// Delete removes the file of the session.
+func (s *FileStore) Delete(session *Session) error {
+	path, err := s.path(session.GetID())
+	if err != nil {
+		return err
+	}
+
+	if err := s.lock(); err != nil {
+		return err
+	}
+	err = os.Remove(path)
+	s.unlock()
+	// The file may have been removed by gc already.
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return pathless(err)
+	}
+	s.emit(EventDestroyed, session)
+	return nil
+}
+
+// load reads the session with the id, it returns nil if the session doesn't exist or has expired.
+// IDs too long to be file names, e.g. sent in forged cookies, can't be stored, so they are missing.
+func (s *FileStore) load(id string) (*Session, error) {
+	path, err := s.path(id)
+	if err != nil {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		err = pathless(err)
+		s.logLoadError(id, err)
+		return nil, err
+	}
+
+	session, err := decodeSession(s.serializer, data)
+	if err != nil {
+		s.logDecodeError(id, err)
+		return nil, err
+	}
+	// The session may not have been swept by gc yet.
+	if session.data.Expiry <= time.Now().Unix() {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// Range calls fn for every live session in the directory until fn returns false.
+// Files that can't be read are skipped.
+func (s *FileStore) Range(ctx context.Context, fn func(session *Session) bool) error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, sessionFileExt) {
+			continue
+		}
+		id, err := hex.DecodeString(strings.TrimSuffix(name, sessionFileExt))
+		if err != nil {
+			continue
+		}
+		session, err := s.load(string(id))
+		if err != nil || session == nil {
+			continue
+		}
+		session.data.IsNew = false
+		if !fn(session) {
+			return nil
+		}
+	}
+	return nil
+}
+
+// path returns the file path of the session with the id.
+// The id comes from a cookie, so it's validated and hex encoded
+// to rule out path traversal and characters that aren't allowed in file names.
+func (s *FileStore) path(id string) (string, error) {
+	if len(id) > maxFileIDLength || !isSessionIDValid(id) {
+		return "", ErrInvalidSessionID
+	}
+	return filepath.Join(s.dir, hex.EncodeToString([]byte(id))+sessionFileExt), nil
+}
+
+// generateID generates a unique session ID.
+func (s *FileStore) generateID() (string, error) {
+	for {
+		id, err := generateRandomID(s.idLength)
+		if err != nil {
+			return "", err
+		}
+		path, err := s.path(id)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+			return id, nil
+		} else if err != nil {
+			return "", pathless(err)
+		}
+		s.logCollision(id)
+	}
+}
+
+// fileID returns the session ID the file is named after, or the file name if it isn't named after one.
+func fileID(name string) string {
+	id, err := hex.DecodeString(strings.TrimSuffix(name, sessionFileExt))
+	if err != nil {
+		return name
+	}
+	return string(id)
+}
+
+// pathless strips the path from file system errors, the file names are hex encoded session IDs,
+// which mustn't end up in errors and logs.
+func pathless(err error) error {
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		return fmt.Errorf("%s: %w", pathErr.Op, pathErr.Err)
+	}
+	var linkErr *os.LinkError
+	if errors.As(err, &linkErr) {
+		return fmt.Errorf("%s: %w", linkErr.Op, linkErr.Err)
+	}
+	return err
+}
+
+// lock takes the writer lock of the process and of the directory.
+func (s *FileStore) lock() error {
+	s.mutex.Lock()
+	if err := lockFile(s.lockFile); err != nil {
+		s.mutex.Unlock()
+		return fmt.Errorf("failed to lock session directory: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) unlock() {
+	_ = unlockFile(s.lockFile)
+	s.mutex.Unlock()
+}
+
+// gc periodically removes expired session files
+func (s *FileStore) gc() {
+	ticker := time.NewTicker(s.gcInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := s.sweep(); err != nil {
+			s.logSweepError(err)
+		}
+	}
+}
+
+// sweep removes the files of expired sessions.
+// Files that can't be decoded are left alone and logged, they may hold sessions written with another codec.
+func (s *FileStore) sweep() error {
+	start := time.Now()
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+	if err := s.lock(); err != nil {
+		return err
+	}
+
+	var expired []*Session
+	removed := 0
+	now := time.Now().Unix()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), sessionFileExt) {
+			continue
+		}
+		path := filepath.Join(s.dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		session, err := decodeSession(s.serializer, data)
+		if err != nil {
+			s.logger.Warn("sessions: skipping session file that can't be decoded",
+				sessionAttr(fileID(entry.Name())), slog.Any("error", err))
+		} else if session.data.Expiry <= now {
+			_ = os.Remove(path)
+			removed++
+			expired = append(expired, session)
+		}
+	}
+	s.unlock()
+	s.observeSweep(start, removed)
+
+	for _, session := range expired {
+		s.emit(EventExpired, session)
+	}
+	return nil
+}
+
+// writeFileAtomic writes data into a temp file in dir, then renames it to path,
+// so the file at path is either the old or the new content.
+func writeFileAtomic(dir, path string, data []byte) error {
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Remove fails harmlessly once the temp file has been renamed.
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,93 @@
+package sessions
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFileStore_SurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir)
+	assert.NoError(t, err)
+
+	session, err := store.New("session-key")
+	assert.NoError(t, err)
+	session.SetValue("name", "Coco")
+	assert.NoError(t, store.Save(session))
+
+	// A new store on the same directory sees the session.
+	restarted, err := NewFileStore(dir)
+	assert.NoError(t, err)
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: session.GetID()})
+	loaded, err := restarted.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.False(t, loaded.IsNew())
+	assert.Equal(t, "Coco", loaded.GetValueByKey("name"))
+
+	assert.NoError(t, restarted.Delete(loaded))
+	loaded, err = restarted.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.True(t, loaded.IsNew())
+}
+
+func TestFileStore_RejectsPathTraversal(t *testing.T) {
+	dir := t.TempDir()
+	secret := filepath.Join(filepath.Dir(dir), "secret.session")
+	assert.NoError(t, os.WriteFile(secret, []byte(`{"id":"x","expiry":9999999999}`), 0o600))
+	defer os.Remove(secret)
+
+	store, _ := NewFileStore(dir)
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: "../secret"})
+	session, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.True(t, session.IsNew())
+
+	_, err = store.(*FileStore).path("../secret")
+	assert.Error(t, err)
+}
+
+func TestFileStore_SweepsExpiredSessions(t *testing.T) {
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir)
+	fileStore := store.(*FileStore)
+
+	expired, _ := store.New("session-key")
+	expired.SetMaxAge(-1)
+	assert.NoError(t, store.Save(expired))
+	live, _ := store.New("session-key")
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "corrupt"+sessionFileExt), []byte("garbage"), 0o600))
+
+	assert.NoError(t, fileStore.sweep())
+	entries, _ := filepath.Glob(filepath.Join(dir, "*"+sessionFileExt))
+	livePath, _ := fileStore.path(live.GetID())
+	// Files that can't be decoded aren't removed.
+	assert.ElementsMatch(t, []string{filepath.Join(dir, "corrupt"+sessionFileExt), livePath}, entries)
+
+	// Expired sessions are ignored even before gc sweeps them.
+	expired.SetMaxAge(-1)
+	assert.NoError(t, store.Save(expired))
+	loaded, err := fileStore.load(expired.GetID())
+	assert.NoError(t, err)
+	assert.Nil(t, loaded)
+}
+
+func TestFileStore_RejectsLongSessionIDs(t *testing.T) {
+	store, _ := NewFileStore(t.TempDir())
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: strings.Repeat("a", 200)})
+	session, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.True(t, session.IsNew())
+
+	assert.Panics(t, func() { _, _ = NewFileStore(t.TempDir(), WithFileSessionIDLength(maxFileIDLength+1)) })
+	store, _ = NewFileStore(t.TempDir(), WithFileSessionIDLength(maxFileIDLength))
+	_, err = store.New("session-key")
+	assert.NoError(t, err)
+}
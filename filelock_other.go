@@ -0,0 +1,15 @@
+//go:build !unix
+
+package sessions
+
+import "os"
+
+// lockFile is a no-op where flock isn't available,
+// writers are then only serialized within a single process.
+func lockFile(_ *os.File) error {
+	return nil
+}
+
+func unlockFile(_ *os.File) error {
+	return nil
+}
@@ -0,0 +1,104 @@
+package sessions
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// FingerprintPolicy binds a session to a fingerprint of the client who created it,
+// so a stolen session cookie can't be used from another client.
+type FingerprintPolicy struct {
+	// UserAgent binds the session to the User-Agent of the client.
+	UserAgent bool
+	// IPv4Prefix and IPv6Prefix bind the session to the subnet of the client IP,
+	// e.g. 24 for a /24 IPv4 network. 0 disables binding for the address family.
+	IPv4Prefix int
+	IPv6Prefix int
+	// TLSChannel binds the session to the TLS connection (RFC 9266 tls-exporter).
+	// Clients have to log in again whenever they open a new connection,
+	// so only enable it when connections are long-lived.
+	TLSChannel bool
+	// Invalidate deletes the session and issues a new one on mismatch,
+	// instead of returning ErrSessionHijackSuspected.
+	Invalidate bool
+	// OnMismatch is called on every mismatch, e.g. for logging. It is optional.
+	OnMismatch func(r *http.Request, session *Session)
+}
+
+// fingerprint returns the fingerprint of the client who sent the request.
+func (p *FingerprintPolicy) fingerprint(r *http.Request, trustedProxies []*net.IPNet) string {
+	var parts []string
+	if p.UserAgent {
+		parts = append(parts, "ua="+r.UserAgent())
+	}
+	if p.IPv4Prefix > 0 || p.IPv6Prefix > 0 {
+		parts = append(parts, "ip="+p.subnet(clientIP(r, trustedProxies)))
+	}
+	if p.TLSChannel {
+		parts = append(parts, "tls="+tlsChannel(r))
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// subnet masks the ip address with the prefix of its address family.
+func (p *FingerprintPolicy) subnet(addr string) string {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return addr
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		if p.IPv4Prefix <= 0 {
+			return "ipv4"
+		}
+		return ip4.Mask(net.CIDRMask(p.IPv4Prefix, 8*net.IPv4len)).String()
+	}
+	if p.IPv6Prefix <= 0 {
+		return "ipv6"
+	}
+	return ip.Mask(net.CIDRMask(p.IPv6Prefix, 8*net.IPv6len)).String()
+}
+
+// tlsChannel returns the channel binding of the TLS connection,
+// or an empty string if the request wasn't sent over TLS.
+func tlsChannel(r *http.Request) string {
+	if r.TLS == nil {
+		return ""
+	}
+	if binding, err := r.TLS.ExportKeyingMaterial("EXPORTER-Channel-Binding", nil, 32); err == nil {
+		return hex.EncodeToString(binding)
+	}
+	// tls-unique, only available before TLS 1.3
+	return hex.EncodeToString(r.TLS.TLSUnique)
+}
+
+// verifyFingerprint reports whether the request comes from the client the session is bound to.
+// Sessions that aren't bound yet, e.g. created before the policy was enabled, always match.
+// The OnMismatch hook of the policy is called on mismatch.
+func (b *baseStore) verifyFingerprint(session *Session, r *http.Request) bool {
+	if b.fingerprint == nil {
+		return true
+	}
+	session.mutex.RLock()
+	expected := session.data.Fingerprint
+	session.mutex.RUnlock()
+	if expected == "" {
+		return true
+	}
+
+	actual := b.fingerprint.fingerprint(r, b.trustedProxies)
+	if subtle.ConstantTimeCompare([]byte(expected), []byte(actual)) == 1 {
+		return true
+	}
+	b.logger.Warn("sessions: session fingerprint mismatch, possible hijacking",
+		sessionAttr(session.GetID()), slog.String("ip", clientIP(r, b.trustedProxies)))
+	if b.fingerprint.OnMismatch != nil {
+		b.fingerprint.OnMismatch(r, session)
+	}
+	return false
+}
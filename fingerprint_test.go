@@ -0,0 +1,85 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newFingerprintRequest(cookie *http.Cookie, remoteAddr, userAgent string) *http.Request {
+	req := httptest.NewRequest("GET", "http://example.com", nil)
+	req.RemoteAddr = remoteAddr
+	req.Header.Set("User-Agent", userAgent)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	return req
+}
+
+func TestMemoryStore_FingerprintMismatch(t *testing.T) {
+	var mismatches int
+	store, _ := NewMemoryStore(WithFingerprint(FingerprintPolicy{
+		UserAgent:  true,
+		IPv4Prefix: 24,
+		OnMismatch: func(r *http.Request, session *Session) { mismatches++ },
+	}))
+
+	session, err := store.Get(newFingerprintRequest(nil, "203.0.113.7:1234", "firefox"), "session-key")
+	assert.NoError(t, err)
+	cookie := &http.Cookie{Name: "session-key", Value: session.GetID()}
+
+	// Same User-Agent within the same /24 network.
+	same, err := store.Get(newFingerprintRequest(cookie, "203.0.113.99:4321", "firefox"), "session-key")
+	assert.NoError(t, err)
+	assert.Same(t, session, same)
+
+	_, err = store.Get(newFingerprintRequest(cookie, "198.51.100.1:1234", "firefox"), "session-key")
+	assert.ErrorIs(t, err, ErrSessionHijackSuspected)
+	_, err = store.Get(newFingerprintRequest(cookie, "203.0.113.7:1234", "curl"), "session-key")
+	assert.ErrorIs(t, err, ErrSessionHijackSuspected)
+	assert.Equal(t, 2, mismatches)
+}
+
+func TestMemoryStore_FingerprintInvalidate(t *testing.T) {
+	store, _ := NewMemoryStore(WithFingerprint(FingerprintPolicy{UserAgent: true, Invalidate: true}))
+
+	session, _ := store.Get(newFingerprintRequest(nil, "203.0.113.7:1234", "firefox"), "session-key")
+	cookie := &http.Cookie{Name: "session-key", Value: session.GetID()}
+
+	stolen, err := store.Get(newFingerprintRequest(cookie, "203.0.113.7:1234", "curl"), "session-key")
+	assert.NoError(t, err)
+	assert.True(t, stolen.IsNew())
+	assert.NotEqual(t, session.GetID(), stolen.GetID())
+
+	// The original session has been invalidated for its owner as well.
+	again, _ := store.Get(newFingerprintRequest(cookie, "203.0.113.7:1234", "firefox"), "session-key")
+	assert.True(t, again.IsNew())
+}
+
+func TestFileStore_FingerprintBehindProxy(t *testing.T) {
+	store, _ := NewFileStore(t.TempDir(),
+		WithFileTrustedProxies("10.0.0.0/8"),
+		WithFileFingerprint(FingerprintPolicy{IPv4Prefix: 24, Invalidate: true}))
+
+	req := newFingerprintRequest(nil, "10.0.0.1:1234", "firefox")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	session, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7", session.GetIP())
+	cookie := &http.Cookie{Name: "session-key", Value: session.GetID()}
+
+	req = newFingerprintRequest(cookie, "10.0.0.2:1234", "firefox")
+	req.Header.Set("X-Forwarded-For", "203.0.113.99")
+	same, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.Equal(t, session.GetID(), same.GetID())
+
+	req = newFingerprintRequest(cookie, "10.0.0.2:1234", "firefox")
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	stolen, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.True(t, stolen.IsNew())
+	assert.NotEqual(t, session.GetID(), stolen.GetID())
+}
@@ -0,0 +1,240 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Metrics receives the measurements of an instrumented store,
+// implement it with counters, histograms and gauges of e.g. Prometheus.
+// Its methods are called concurrently.
+type Metrics interface {
+	// ObserveOperation records a call of a Store method: "get", "new", "save" or "delete".
+	// err is the error returned by the call, nil if it succeeded.
+	ObserveOperation(op string, duration time.Duration, err error)
+
+	// AddActiveSessions adds delta to the gauge of active sessions.
+	AddActiveSessions(delta int)
+
+	// ObserveSweep records a GC sweep of expired sessions and how many sessions it removed.
+	ObserveSweep(duration time.Duration, removed int)
+
+	// ObserveSerializedSize records the size in bytes of a session serialized by Save.
+	ObserveSerializedSize(bytes int)
+}
+
+// Tracer starts spans, implement it with an adapter around an OpenTelemetry trace.Tracer.
+type Tracer interface {
+	// Start starts a span and returns a context carrying it.
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// InstrumentedStore is a Store recording metrics and spans of the Store it wraps.
+type InstrumentedStore struct {
+	store   Store
+	metrics Metrics
+	tracer  Tracer
+}
+
+// Instrument wraps store so its operations are recorded by metrics and traced by tracer,
+// either may be nil.
+//
+// Stores of this package also report their GC sweeps and the sizes of serialized sessions to metrics,
+// and the active sessions gauge follows their events: created sessions are added,
+// destroyed and expired ones are subtracted, each session is subtracted once.
+// Sessions expiring in Redis are only subtracted if the RedisStore is created with WithRedisExpiryEvents.
+// The commands a RedisStore or TieredStore sends to Redis are traced with a hook added to its client,
+// so other commands sent with the client are traced as well.
+//
+// The wrapper only implements Store, use Unwrap to reach the other interfaces of the store.
+func Instrument(store Store, metrics Metrics, tracer Tracer) *InstrumentedStore {
+	if metrics != nil {
+		if s, ok := store.(interface{ setMetrics(Metrics) }); ok {
+			s.setMetrics(metrics)
+		}
+		if s, ok := store.(EventSource); ok {
+			s.AddListener(func(event Event) {
+				switch event.Type {
+				case EventCreated:
+					metrics.AddActiveSessions(1)
+				case EventDestroyed, EventExpired:
+					metrics.AddActiveSessions(-1)
+				}
+			})
+		}
+	}
+	if tracer != nil {
+		if s, ok := store.(interface{ traceRedis(Tracer) }); ok {
+			s.traceRedis(tracer)
+		}
+	}
+	return &InstrumentedStore{store: store, metrics: metrics, tracer: tracer}
+}
+
+// Unwrap returns the wrapped store.
+func (s *InstrumentedStore) Unwrap() Store {
+	return s.store
+}
+
+// Get calls Get of the wrapped store, the span is a child of the span in the request context.
+func (s *InstrumentedStore) Get(r *http.Request, name string) (*Session, error) {
+	ctx, done := s.start(r.Context(), "get")
+	session, err := s.store.Get(r.WithContext(ctx), name)
+	done(err)
+	return session, err
+}
+
+// New calls New of the wrapped store.
+func (s *InstrumentedStore) New(name string) (*Session, error) {
+	ctx, done := s.start(context.Background(), "new")
+	var session *Session
+	var err error
+	if store, ok := s.store.(contextStore); ok {
+		session, err = store.newContext(ctx, name)
+	} else {
+		session, err = s.store.New(name)
+	}
+	done(err)
+	return session, err
+}
+
+// Save calls Save of the wrapped store.
+func (s *InstrumentedStore) Save(session *Session) error {
+	ctx, done := s.start(context.Background(), "save")
+	var err error
+	if store, ok := s.store.(contextStore); ok {
+		err = store.saveContext(ctx, session)
+	} else {
+		err = s.store.Save(session)
+	}
+	done(err)
+	return err
+}
+
+// Delete calls Delete of the wrapped store.
+func (s *InstrumentedStore) Delete(session *Session) error {
+	ctx, done := s.start(context.Background(), "delete")
+	var err error
+	if store, ok := s.store.(contextStore); ok {
+		err = store.deleteContext(ctx, session)
+	} else {
+		err = s.store.Delete(session)
+	}
+	done(err)
+	return err
+}
+
+// contextStore is implemented by stores which send the commands of New, Save and Delete with a context,
+// so the spans of the commands are children of the span of the operation.
+type contextStore interface {
+	newContext(ctx context.Context, name string) (*Session, error)
+	saveContext(ctx context.Context, session *Session) error
+	deleteContext(ctx context.Context, session *Session) error
+}
+
+// start starts recording the operation op, call done with its error once it returns.
+func (s *InstrumentedStore) start(ctx context.Context, op string) (_ context.Context, done func(err error)) {
+	start := time.Now()
+	var span Span
+	if s.tracer != nil {
+		ctx, span = s.tracer.Start(ctx, "sessions."+op)
+		span.SetAttribute("sessions.operation", op)
+	}
+	return ctx, func(err error) {
+		if s.metrics != nil {
+			s.metrics.ObserveOperation(op, time.Since(start), err)
+		}
+		if span != nil {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.End()
+		}
+	}
+}
+
+// setMetrics makes the store report its sweeps and serialized sizes to metrics.
+func (b *baseStore) setMetrics(metrics Metrics) {
+	b.metrics.Store(&metrics)
+}
+
+// observeSweep reports a sweep which started at start and removed some sessions.
+func (b *baseStore) observeSweep(start time.Time, removed int) {
+	duration := time.Since(start)
+	b.logSweep(duration, removed)
+	if metrics := b.metrics.Load(); metrics != nil {
+		(*metrics).ObserveSweep(duration, removed)
+	}
+}
+
+// observeSize reports the size of a serialized session.
+func (b *baseStore) observeSize(size int) {
+	if metrics := b.metrics.Load(); metrics != nil {
+		(*metrics).ObserveSerializedSize(size)
+	}
+}
+
+func (s *RedisStore) traceRedis(tracer Tracer) {
+	s.client.AddHook(redisTracingHook{tracer: tracer})
+}
+
+func (s *TieredStore) setMetrics(metrics Metrics) {
+	s.remote.setMetrics(metrics)
+}
+
+func (s *TieredStore) traceRedis(tracer Tracer) {
+	s.remote.traceRedis(tracer)
+}
+
+// redisTracingHook starts a span for every command and pipeline sent to Redis.
+type redisTracingHook struct {
+	tracer Tracer
+}
+
+func (h redisTracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (h redisTracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := h.tracer.Start(ctx, "redis."+cmd.Name())
+		span.SetAttribute("db.system", "redis")
+		span.SetAttribute("db.operation", cmd.Name())
+		err := next(ctx, cmd)
+		h.end(span, err)
+		return err
+	}
+}
+
+func (h redisTracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := h.tracer.Start(ctx, "redis.pipeline")
+		span.SetAttribute("db.system", "redis")
+		span.SetAttribute("db.redis.num_cmd", len(cmds))
+		err := next(ctx, cmds)
+		h.end(span, err)
+		return err
+	}
+}
+
+// end ends the span, redis.Nil only means a key doesn't exist so it isn't recorded as an error.
+func (h redisTracingHook) end(span Span, err error) {
+	if err != nil && !errors.Is(err, redis.Nil) {
+		span.RecordError(err)
+	}
+	span.End()
+}
@@ -0,0 +1,197 @@
+package sessions
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMetrics struct {
+	mutex      sync.Mutex
+	operations map[string]int
+	errors     int
+	active     int
+	sweeps     int
+	sizes      []int
+}
+
+func (m *fakeMetrics) ObserveOperation(op string, _ time.Duration, err error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if m.operations == nil {
+		m.operations = make(map[string]int)
+	}
+	m.operations[op]++
+	if err != nil {
+		m.errors++
+	}
+}
+
+func (m *fakeMetrics) AddActiveSessions(delta int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.active += delta
+}
+
+func (m *fakeMetrics) ObserveSweep(time.Duration, int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sweeps++
+}
+
+func (m *fakeMetrics) ObserveSerializedSize(bytes int) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.sizes = append(m.sizes, bytes)
+}
+
+type fakeTracer struct {
+	mutex sync.Mutex
+	spans []*fakeSpan
+}
+
+type fakeSpan struct {
+	name   string
+	parent *fakeSpan
+	attrs  map[string]interface{}
+	err    error
+	ended  bool
+}
+
+type fakeSpanKey struct{}
+
+func (t *fakeTracer) Start(ctx context.Context, name string) (context.Context, Span) {
+	parent, _ := ctx.Value(fakeSpanKey{}).(*fakeSpan)
+	span := &fakeSpan{name: name, parent: parent, attrs: make(map[string]interface{})}
+	t.mutex.Lock()
+	t.spans = append(t.spans, span)
+	t.mutex.Unlock()
+	return context.WithValue(ctx, fakeSpanKey{}, span), span
+}
+
+func (s *fakeSpan) SetAttribute(key string, value interface{}) { s.attrs[key] = value }
+func (s *fakeSpan) RecordError(err error)                      { s.err = err }
+func (s *fakeSpan) End()                                       { s.ended = true }
+
+func TestInstrument(t *testing.T) {
+	memoryStore, _ := NewMemoryStore(WithGCInterval(20 * time.Millisecond))
+	metrics, tracer := &fakeMetrics{}, &fakeTracer{}
+	store := Instrument(memoryStore, metrics, tracer)
+	assert.Equal(t, memoryStore, store.Unwrap())
+
+	session, err := store.New("session-key")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Save(session))
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	_, err = store.Get(req, "invalid name")
+	assert.Error(t, err)
+	assert.NoError(t, store.Delete(session))
+
+	metrics.mutex.Lock()
+	assert.Equal(t, map[string]int{"new": 1, "save": 1, "get": 1, "delete": 1}, metrics.operations)
+	assert.Equal(t, 1, metrics.errors)
+	assert.Equal(t, 0, metrics.active)
+	metrics.mutex.Unlock()
+
+	assert.Len(t, tracer.spans, 4)
+	assert.Equal(t, "sessions.get", tracer.spans[2].name)
+	assert.Error(t, tracer.spans[2].err)
+	for _, span := range tracer.spans {
+		assert.True(t, span.ended)
+	}
+
+	assert.Eventually(t, func() bool {
+		metrics.mutex.Lock()
+		defer metrics.mutex.Unlock()
+		return metrics.sweeps > 0
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestInstrument_RedisStore(t *testing.T) {
+	redisStore, _ := NewRedisStore(setupRedisClient())
+	metrics, tracer := &fakeMetrics{}, &fakeTracer{}
+	store := Instrument(redisStore, metrics, tracer)
+
+	session, _ := store.New("instrument_session")
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "instrument_session", Value: session.GetID()})
+	_, err := store.Get(req, "instrument_session")
+	assert.NoError(t, err)
+
+	metrics.mutex.Lock()
+	assert.NotEmpty(t, metrics.sizes)
+	assert.Equal(t, 1, metrics.active)
+	metrics.mutex.Unlock()
+
+	// Redis commands of Get are children of the span of Get.
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	var get *fakeSpan
+	for _, span := range tracer.spans {
+		if span.name == "sessions.get" {
+			get = span
+		}
+	}
+	if assert.NotNil(t, get) {
+		var children []string
+		for _, span := range tracer.spans {
+			if span.parent == get {
+				children = append(children, span.name)
+				assert.Equal(t, "redis", span.attrs["db.system"])
+			}
+		}
+		assert.Equal(t, []string{"redis.pipeline"}, children)
+	}
+}
+
+func TestInstrument_RedisStoreParentsEveryCommand(t *testing.T) {
+	redisStore, _ := NewRedisStore(setupRedisClient())
+	tracer := &fakeTracer{}
+	store := Instrument(redisStore, nil, tracer)
+
+	session, _ := store.New("instrument_session")
+	assert.NoError(t, store.Save(session))
+	// Without a cookie, Get creates a new session.
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	created, err := store.Get(req, "instrument_session")
+	assert.NoError(t, err)
+	assert.NoError(t, store.Delete(session))
+	assert.NoError(t, store.Delete(created))
+
+	tracer.mutex.Lock()
+	defer tracer.mutex.Unlock()
+	commands := 0
+	for _, span := range tracer.spans {
+		if span.attrs["db.system"] == "redis" {
+			commands++
+			if assert.NotNil(t, span.parent, span.name) {
+				assert.True(t, strings.HasPrefix(span.parent.name, "sessions."), span.parent.name)
+			}
+		}
+	}
+	assert.Greater(t, commands, 5)
+}
+
+func TestInstrument_ActiveSessionsAfterExpiry(t *testing.T) {
+	memoryStore, _ := NewMemoryStore(WithGCInterval(10 * time.Millisecond))
+	metrics := &fakeMetrics{}
+	store := Instrument(memoryStore, metrics, nil)
+
+	session, _ := store.New("session-key")
+	session.SetMaxAge(-1)
+	assert.Eventually(t, func() bool {
+		metrics.mutex.Lock()
+		defer metrics.mutex.Unlock()
+		return metrics.active == 0
+	}, time.Second, 10*time.Millisecond)
+	// Logging out after gc removed the session doesn't subtract it again.
+	assert.NoError(t, store.Delete(session))
+	metrics.mutex.Lock()
+	assert.Equal(t, 0, metrics.active)
+	metrics.mutex.Unlock()
+}
@@ -0,0 +1,123 @@
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+)
+
+// ErrLimitExceeded is returned by Store.Save when a session exceeds the Limits of the store,
+// the error is a *LimitError.
+var ErrLimitExceeded = errors.New("sessions: session limit exceeded")
+
+// Limits bound the size of sessions, they are enforced by Store.Save. Zero limits are unlimited.
+type Limits struct {
+	MaxSize      int // maximum size in bytes of the session written by the store, after its codec
+	MaxKeys      int // maximum number of values
+	MaxValueSize int // maximum size in bytes of a value encoded as JSON
+
+	// WarnRatio is the fraction of a limit from which OnWarning is called, 0.8 if 0.
+	WarnRatio float64
+	// OnWarning is called by Save when a session approaches a limit, warning describes the limit.
+	// It's called while saving, so it shouldn't use the session store.
+	OnWarning func(session *Session, warning *LimitError)
+}
+
+// LimitError describes a session exceeding, or approaching, one of the Limits.
+type LimitError struct {
+	Limit string // "size", "keys" or "value size"
+	Key   string // key of the value, for the "value size" limit
+	Size  int
+	Max   int
+}
+
+func (e *LimitError) Error() string {
+	if e.Key != "" {
+		return fmt.Sprintf("sessions: %s of value %q is %d, limit is %d", e.Limit, e.Key, e.Size, e.Max)
+	}
+	return fmt.Sprintf("sessions: session %s is %d, limit is %d", e.Limit, e.Size, e.Max)
+}
+
+func (e *LimitError) Unwrap() error {
+	return ErrLimitExceeded
+}
+
+// validateLimits returns a copy of limits for a store, it panics if they are invalid
+// like the other options of stores.
+func validateLimits(limits Limits) *Limits {
+	if limits.MaxSize < 0 || limits.MaxKeys < 0 || limits.MaxValueSize < 0 {
+		panic("limits cannot be negative")
+	}
+	if limits.WarnRatio < 0 || limits.WarnRatio > 1 {
+		panic("limit warning ratio must be between 0 and 1")
+	}
+	return &limits
+}
+
+// checkLimits checks the session against the limits of the store, data is the session written by the store,
+// or nil if the store doesn't serialize sessions.
+func (b *baseStore) checkLimits(session *Session, data []byte) error {
+	limits := b.limits
+	if limits == nil {
+		return nil
+	}
+
+	var checks []*LimitError
+	if limits.MaxSize > 0 {
+		if data == nil {
+			var err error
+			if data, err = (&Serializer{}).Serialize(session); err != nil {
+				return err
+			}
+		}
+		checks = append(checks, &LimitError{Limit: "size", Size: len(data), Max: limits.MaxSize})
+	}
+	if limits.MaxKeys > 0 || limits.MaxValueSize > 0 {
+		session.mutex.RLock()
+		keys := len(session.data.Values)
+		var values []*LimitError
+		if limits.MaxValueSize > 0 {
+			for k, v := range session.data.Values {
+				encoded, err := json.Marshal(v)
+				if err != nil {
+					session.mutex.RUnlock()
+					return err
+				}
+				values = append(values, &LimitError{Limit: "value size", Key: k, Size: len(encoded), Max: limits.MaxValueSize})
+			}
+		}
+		session.mutex.RUnlock()
+
+		if limits.MaxKeys > 0 {
+			checks = append(checks, &LimitError{Limit: "keys", Size: keys, Max: limits.MaxKeys})
+		}
+		// Sorted, so the same value is reported first every time.
+		sort.Slice(values, func(i, j int) bool { return values[i].Key < values[j].Key })
+		checks = append(checks, values...)
+	}
+
+	ratio := limits.WarnRatio
+	if ratio == 0 {
+		ratio = 0.8
+	}
+	var warnings []*LimitError
+	for _, check := range checks {
+		if check.Size > check.Max {
+			return check
+		}
+		if float64(check.Size) >= ratio*float64(check.Max) {
+			warnings = append(warnings, check)
+		}
+	}
+	for _, warning := range warnings {
+		b.logger.Warn("sessions: session approaching limit", sessionAttr(session.GetID()),
+			slog.String("limit", warning.Limit), slog.String("key", warning.Key),
+			slog.Int("size", warning.Size), slog.Int("max", warning.Max))
+		if limits.OnWarning != nil {
+			limits.OnWarning(session, warning)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package sessions
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore_Limits(t *testing.T) {
+	var warnings []*LimitError
+	store, _ := NewMemoryStore(WithLimits(Limits{
+		MaxSize:      2048,
+		MaxKeys:      3,
+		MaxValueSize: 100,
+		OnWarning: func(session *Session, warning *LimitError) {
+			warnings = append(warnings, warning)
+		},
+	}))
+	session, _ := store.New("session-key")
+
+	session.SetValue("name", "Coco")
+	assert.NoError(t, store.Save(session))
+	assert.Empty(t, warnings)
+
+	// 90 bytes is over 80% of the value limit.
+	session.SetValue("bio", strings.Repeat("a", 88))
+	assert.NoError(t, store.Save(session))
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, "bio", warnings[0].Key)
+	}
+
+	session.SetValue("bio", strings.Repeat("a", 200))
+	err := store.Save(session)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.EqualError(t, err, `sessions: value size of value "bio" is 202, limit is 100`)
+
+	session.SetValue("bio", "short")
+	session.SetValue("a", 1)
+	session.SetValue("b", 2)
+	var limitErr *LimitError
+	if assert.ErrorAs(t, store.Save(session), &limitErr) {
+		assert.Equal(t, "keys", limitErr.Limit)
+		assert.Equal(t, 4, limitErr.Size)
+	}
+}
+
+func TestFileStore_LimitsMaxSize(t *testing.T) {
+	store, _ := NewFileStore(t.TempDir(), WithFileLimits(Limits{MaxSize: 1024}))
+	session, _ := store.New("session-key")
+	session.SetValue("notes", strings.Repeat("session ", 200))
+	assert.ErrorIs(t, store.Save(session), ErrLimitExceeded)
+
+	// The limit applies to the written size, so compressed sessions may hold more.
+	store, _ = NewFileStore(t.TempDir(), WithFileLimits(Limits{MaxSize: 1024}), WithFileCodec(Compress(nil, 0)))
+	assert.NoError(t, store.Save(session))
+}
+
+func TestMemoryStore_LimitsRollBackRejectedSave(t *testing.T) {
+	store, _ := NewMemoryStore(WithLimits(Limits{MaxKeys: 2, MaxValueSize: 10}))
+	session, _ := store.New("session-key")
+	session.SetValue("blob", "small")
+	assert.NoError(t, store.Save(session))
+	session.SetValue("blob", strings.Repeat("a", 1<<20))
+	assert.ErrorIs(t, store.Save(session), ErrLimitExceeded)
+
+	// The session is kept with the values of its last save.
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: session.GetID()})
+	loaded, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.False(t, loaded.IsNew())
+	assert.Equal(t, "small", loaded.GetValueByKey("blob"))
+
+	// Counters are limited as well, a rejected increment leaves the session unchanged.
+	n, err := store.(Counter).Incr(session, "visits", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	_, err = store.(Counter).Incr(session, "clicks", 1)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Nil(t, loaded.GetValueByKey("clicks"))
+	_, err = store.(Counter).Incr(session, "visits", 1<<40)
+	assert.ErrorIs(t, err, ErrLimitExceeded)
+	assert.Equal(t, int64(1), loaded.GetValueByKey("visits"))
+
+	// The counter was accepted by the store, so it survives a rejected save.
+	session.SetValue("blob", strings.Repeat("a", 100))
+	assert.ErrorIs(t, store.Save(session), ErrLimitExceeded)
+	assert.Equal(t, int64(1), loaded.GetValueByKey("visits"))
+}
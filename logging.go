@@ -0,0 +1,66 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"time"
+)
+
+// discardLogger is the logger of stores created without one.
+var discardLogger = slog.New(discardHandler{})
+
+// discardHandler drops all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
+
+// redactID returns a short hash of a session ID, session IDs are credentials and are never logged.
+// The same ID always gives the same hash, so log lines of a session can still be correlated.
+func redactID(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:6])
+}
+
+// sessionAttr returns the log attribute of the session with the id.
+func sessionAttr(id string) slog.Attr {
+	return slog.String("session", redactID(id))
+}
+
+// setLogger sets the logger of the store, nil disables logging.
+func (b *baseStore) setLogger(logger *slog.Logger) {
+	if logger == nil {
+		logger = discardLogger
+	}
+	b.logger = logger
+}
+
+// logLoadError logs that the session with the id couldn't be read from the store.
+func (b *baseStore) logLoadError(id string, err error) {
+	b.logger.Error("sessions: loading session failed", sessionAttr(id), slog.Any("error", err))
+}
+
+// logDecodeError logs that the stored data of the session with the id couldn't be deserialized.
+func (b *baseStore) logDecodeError(id string, err error) {
+	b.logger.Warn("sessions: decoding session failed", sessionAttr(id), slog.Any("error", err))
+}
+
+// logCollision logs that a generated session ID is already used by another session.
+func (b *baseStore) logCollision(id string) {
+	b.logger.Warn("sessions: session ID collision, generating another one", sessionAttr(id))
+}
+
+// logSweep logs the stats of a GC sweep.
+func (b *baseStore) logSweep(duration time.Duration, removed int) {
+	b.logger.Debug("sessions: swept expired sessions",
+		slog.Int("removed", removed), slog.Duration("duration", duration))
+}
+
+// logSweepError logs that a GC sweep failed.
+func (b *baseStore) logSweepError(err error) {
+	b.logger.Error("sessions: sweeping expired sessions failed", slog.Any("error", err))
+}
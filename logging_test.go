@@ -0,0 +1,97 @@
+package sessions
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// syncBuffer is a bytes.Buffer safe for the concurrent writes of loggers.
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestMemoryStore_Logger(t *testing.T) {
+	out := &syncBuffer{}
+	logger := slog.New(slog.NewTextHandler(out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	store, _ := NewMemoryStore(WithLogger(logger))
+
+	session, _ := store.New("session-key")
+	logs := out.String()
+	assert.Contains(t, logs, "sessions: session created")
+	assert.Contains(t, logs, "session="+redactID(session.GetID()))
+	assert.NotContains(t, logs, session.GetID(), "session IDs must not be logged")
+}
+
+func TestRedisStore_LoggerDecodeError(t *testing.T) {
+	out := &syncBuffer{}
+	client := setupRedisClient()
+	store, _ := NewRedisStore(client, WithRedisLogger(slog.New(slog.NewTextHandler(out, nil))))
+
+	id := "corrupt-logged-session"
+	client.Set(context.Background(), id, "not a session", 0)
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "corrupt_session", Value: id})
+	_, err := store.Get(req, "corrupt_session")
+	assert.Error(t, err)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 1)
+	assert.Contains(t, lines[0], "level=WARN")
+	assert.Contains(t, lines[0], "sessions: decoding session failed")
+	assert.NotContains(t, lines[0], id)
+}
+
+func TestFileStore_LoggerOmitsFileNames(t *testing.T) {
+	out := &syncBuffer{}
+	dir := t.TempDir()
+	store, _ := NewFileStore(dir, WithFileLogger(slog.New(slog.NewTextHandler(out, nil))))
+	fileStore := store.(*FileStore)
+
+	// A directory can't be read as a session file.
+	unreadable := "unreadablesession"
+	path, _ := fileStore.path(unreadable)
+	assert.NoError(t, os.Mkdir(path, 0o700))
+	_, err := fileStore.load(unreadable)
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), filepath.Base(path))
+
+	corrupt := "corruptsession01"
+	path, _ = fileStore.path(corrupt)
+	assert.NoError(t, os.WriteFile(path, []byte("garbage"), 0o600))
+	assert.NoError(t, fileStore.sweep())
+
+	logs := out.String()
+	for _, id := range []string{unreadable, corrupt} {
+		assert.Contains(t, logs, "session="+redactID(id))
+		assert.NotContains(t, logs, hex.EncodeToString([]byte(id)))
+	}
+}
+
+func TestRedactID(t *testing.T) {
+	assert.Equal(t, redactID("id"), redactID("id"))
+	assert.NotEqual(t, redactID("id"), redactID("other"))
+	assert.Len(t, redactID("id"), 12)
+}
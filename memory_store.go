@@ -1,8 +1,11 @@
 package sessions
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 )
@@ -11,7 +14,12 @@ type MemoryStore struct {
 	*baseStore
 	mutex      sync.RWMutex
 	sessions   map[string]*Session
+	users      map[string]map[string]struct{} // user ID -> IDs of the sessions bound to the user
 	gcInterval time.Duration
+
+	snapshotPath     string // file sessions are restored from on start and periodically written to
+	snapshotInterval time.Duration
+	snapshotCodec    Codec
 }
 
 // NewMemoryStore creates and returns a new MemoryStore
@@ -25,7 +33,10 @@ func NewMemoryStore(options ...func(store *MemoryStore)) (Store, error) {
 	store := &MemoryStore{
 		baseStore:  base,
 		sessions:   make(map[string]*Session),
+		users:      make(map[string]map[string]struct{}),
 		gcInterval: 500 * time.Millisecond,
+
+		snapshotCodec: &Serializer{},
 	}
 
 	// Apply custom options
@@ -33,6 +44,13 @@ func NewMemoryStore(options ...func(store *MemoryStore)) (Store, error) {
 		op(store)
 	}
 
+	if store.snapshotPath != "" {
+		if err := store.loadSnapshotFile(); err != nil {
+			return nil, fmt.Errorf("failed to restore snapshot: %w", err)
+		}
+		go store.snapshotPeriodically()
+	}
+
 	go store.gc()
 
 	return store, nil
@@ -41,21 +59,37 @@ func NewMemoryStore(options ...func(store *MemoryStore)) (Store, error) {
 // Get returns a session if exists, if it doesn't exist, create a new one.
 func (s *MemoryStore) Get(r *http.Request, name string) (*Session, error) {
 	if !isCookieNameValid(name) {
-		return nil, fmt.Errorf("sessions: invalid character in cookie name: %s", name)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCookieName, name)
 	}
 	if c, err := r.Cookie(name); err == nil {
 		// check if there is a corresponding session in MemoryStore.
 		s.mutex.RLock()
 		session, ok := s.sessions[c.Value]
 		s.mutex.RUnlock()
+		if ok && !s.verifyFingerprint(session, r) {
+			if s.fingerprint.Invalidate {
+				_ = s.Delete(session)
+				ok = false
+			} else {
+				return nil, ErrSessionHijackSuspected
+			}
+		}
 		if ok {
-			session.data.IsNew = false
+			session.SetIsNew(false)
+			s.touch(session, r)
+			s.emit(EventLoaded, session)
 			return session, nil
 		}
+		s.emitID(EventMissed, c.Value, name)
 	}
 	// cookie doesn't exist or no corresponding session stored in MemoryStore
 	// generate a new session.
-	return s.New(name)
+	session, err := s.New(name)
+	if err != nil {
+		return nil, err
+	}
+	s.touch(session, r)
+	return session, nil
 }
 
 // New Returns a new session and saves it into underlying store
@@ -65,27 +99,243 @@ func (s *MemoryStore) New(name string) (*Session, error) {
 		return nil, err
 	}
 	session := NewSession(name, id, *s.options)
+	if s.limits != nil {
+		session.commit()
+	}
 	// saves session into underlying store
 	s.mutex.Lock()
 	s.sessions[session.data.ID] = session
 	s.mutex.Unlock()
+	s.emit(EventCreated, session)
 	return session, nil
 }
 
+// Save stores the session. The store holds the session itself rather than a copy,
+// so if the session exceeds the limits of the store, its values are rolled back to the ones of its last save.
+// Values are copied shallowly, changes made inside maps or slices stored in the session aren't rolled back.
 func (s *MemoryStore) Save(session *Session) error {
+	if err := s.checkLimits(session, nil); err != nil {
+		s.mutex.RLock()
+		stored := s.sessions[session.data.ID]
+		s.mutex.RUnlock()
+		if stored == session {
+			session.rollback()
+		}
+		return err
+	}
+	if s.limits != nil {
+		session.commit()
+	}
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
 	s.sessions[session.data.ID] = session
+	s.mutex.Unlock()
+	s.emit(EventSaved, session)
 	return nil
 }
 
+// Delete removes the session from the store, EventDestroyed is only emitted if it was stored.
 func (s *MemoryStore) Delete(session *Session) error {
 	s.mutex.Lock()
-	defer s.mutex.Unlock()
+	s.unbindUser(session.GetUserID(), session.data.ID)
+	_, stored := s.sessions[session.data.ID]
 	delete(s.sessions, session.data.ID)
+	s.mutex.Unlock()
+	if stored {
+		s.emit(EventDestroyed, session)
+	}
+	return nil
+}
+
+// BindUser binds the session to the user.
+func (s *MemoryStore) BindUser(session *Session, userID string) error {
+	if userID == "" {
+		return ErrInvalidUserID
+	}
+	session.mutex.Lock()
+	oldUserID := session.data.UserID
+	session.data.UserID = userID
+	session.mutex.Unlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.unbindUser(oldUserID, session.data.ID)
+	ids, ok := s.users[userID]
+	if !ok {
+		ids = make(map[string]struct{})
+		s.users[userID] = ids
+	}
+	ids[session.data.ID] = struct{}{}
+	s.sessions[session.data.ID] = session
+	return nil
+}
+
+// ListByUser returns the live sessions bound to the user.
+func (s *MemoryStore) ListByUser(userID string) ([]*Session, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	now := time.Now().Unix()
+	sessions := make([]*Session, 0, len(s.users[userID]))
+	for id := range s.users[userID] {
+		if session, ok := s.sessions[id]; ok && session.data.Expiry > now {
+			sessions = append(sessions, session)
+		}
+	}
+	return sessions, nil
+}
+
+// RevokeUser deletes all sessions bound to the user.
+func (s *MemoryStore) RevokeUser(userID string) error {
+	var revoked []*Session
+	s.mutex.Lock()
+	for id := range s.users[userID] {
+		if session, ok := s.sessions[id]; ok {
+			revoked = append(revoked, session)
+		}
+		delete(s.sessions, id)
+	}
+	delete(s.users, userID)
+	s.mutex.Unlock()
+
+	for _, session := range revoked {
+		s.emit(EventDestroyed, session)
+	}
+	return nil
+}
+
+// Incr increments the value of key in the stored session under its lock.
+// It returns ErrNotFound if the session isn't in the store,
+// and a *LimitError, leaving the value unchanged, if the session would exceed the limits of the store.
+func (s *MemoryStore) Incr(session *Session, key string, delta int64) (int64, error) {
+	s.mutex.RLock()
+	stored, ok := s.sessions[session.GetID()]
+	s.mutex.RUnlock()
+	if !ok {
+		return 0, ErrNotFound
+	}
+	stored.mutex.Lock()
+	_, existed := stored.data.Values[key]
+	n := toInt64(stored.data.Values[key]) + delta
+	stored.data.Values[key] = n
+	stored.mutex.Unlock()
+	if err := s.checkLimits(stored, nil); err != nil {
+		// Subtracting delta keeps concurrent increments.
+		stored.mutex.Lock()
+		if existed {
+			stored.data.Values[key] = toInt64(stored.data.Values[key]) - delta
+		} else {
+			delete(stored.data.Values, key)
+		}
+		stored.mutex.Unlock()
+		return 0, err
+	}
+	stored.commitValue(key, n)
+	if stored != session {
+		session.SetValue(key, n)
+	}
+	return n, nil
+}
+
+// Range calls fn for every live session in the store until fn returns false.
+func (s *MemoryStore) Range(ctx context.Context, fn func(session *Session) bool) error {
+	// fn may call back into the store, so it's called without holding the lock.
+	now := time.Now().Unix()
+	s.mutex.RLock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		if session.data.Expiry > now {
+			sessions = append(sessions, session)
+		}
+	}
+	s.mutex.RUnlock()
+
+	for _, session := range sessions {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if !fn(session) {
+			break
+		}
+	}
 	return nil
 }
 
+// Count returns the number of sessions in the store, including expired ones gc hasn't removed yet.
+func (s *MemoryStore) Count() (int, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.sessions), nil
+}
+
+// List returns up to limit live sessions whose IDs sort after the cursor, and the cursor of the next page.
+func (s *MemoryStore) List(cursor string, limit int) ([]*Session, string, error) {
+	if limit <= 0 {
+		return nil, "", errors.New("sessions: limit must be positive")
+	}
+	now := time.Now().Unix()
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	ids := make([]string, 0, len(s.sessions))
+	for id, session := range s.sessions {
+		// Expired sessions gc hasn't removed yet can't be loaded.
+		if id > cursor && session.data.Expiry > now {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	next := ""
+	if len(ids) > limit {
+		ids = ids[:limit]
+		next = ids[limit-1]
+	}
+	sessions := make([]*Session, len(ids))
+	for i, id := range ids {
+		sessions[i] = s.sessions[id]
+	}
+	return sessions, next, nil
+}
+
+// Load returns the session with the id, or ErrNotFound.
+func (s *MemoryStore) Load(id string) (*Session, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	session, ok := s.sessions[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	// The session may not have been removed by gc yet.
+	session.mutex.RLock()
+	expired := session.data.Expiry <= time.Now().Unix()
+	session.mutex.RUnlock()
+	if expired {
+		return nil, ErrExpired
+	}
+	return session, nil
+}
+
+// DeleteByID deletes the session with the id, or returns ErrNotFound.
+func (s *MemoryStore) DeleteByID(id string) error {
+	session, err := s.Load(id)
+	if err != nil {
+		return err
+	}
+	return s.Delete(session)
+}
+
+// unbindUser removes the session id from the index of the user.
+// The caller must hold s.mutex.
+func (s *MemoryStore) unbindUser(userID, id string) {
+	ids, ok := s.users[userID]
+	if !ok {
+		return
+	}
+	delete(ids, id)
+	if len(ids) == 0 {
+		delete(s.users, userID)
+	}
+}
+
 // generateID Generate an unique ID for session
 func (s *MemoryStore) generateID() (string, error) {
 	// generate an unique random string as session ID
@@ -99,6 +349,7 @@ func (s *MemoryStore) generateID() (string, error) {
 			if !ok {
 				return id, nil
 			}
+			s.logCollision(id)
 		}
 	}
 }
@@ -108,12 +359,21 @@ func (s *MemoryStore) gc() {
 	ticker := time.NewTicker(s.gcInterval)
 	defer ticker.Stop()
 	for range ticker.C {
+		start := time.Now()
+		var expired []*Session
 		s.mutex.Lock()
 		for k, session := range s.sessions {
 			if session.data.Expiry <= time.Now().Unix() {
+				s.unbindUser(session.GetUserID(), k)
 				delete(s.sessions, k)
+				expired = append(expired, session)
 			}
 		}
 		s.mutex.Unlock()
+		s.observeSweep(start, len(expired))
+
+		for _, session := range expired {
+			s.emit(EventExpired, session)
+		}
 	}
 }
@@ -1,6 +1,7 @@
 package sessions
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"net/http"
@@ -124,3 +125,136 @@ func TestStore(t *testing.T) {
 		t.Errorf("Expected session.IsNew() = true; Got session.IsNew=%v", session.IsNew())
 	}
 }
+
+func TestMemoryStore_UserIndex(t *testing.T) {
+	store, _ := NewMemoryStore()
+	index := store.(UserIndex)
+
+	laptop, _ := store.New("session-key")
+	phone, _ := store.New("session-key")
+	other, _ := store.New("session-key")
+	if err := index.BindUser(laptop, "coco"); err != nil {
+		t.Fatalf("Error binding user: %v", err)
+	}
+	_ = index.BindUser(phone, "coco")
+	_ = index.BindUser(other, "bella")
+
+	sessions, _ := index.ListByUser("coco")
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions of coco; Got %d", len(sessions))
+	}
+
+	// Rebinding moves the session to the new user.
+	_ = index.BindUser(phone, "bella")
+	if sessions, _ = index.ListByUser("coco"); len(sessions) != 1 {
+		t.Errorf("Expected 1 session of coco after rebinding; Got %d", len(sessions))
+	}
+
+	if err := index.RevokeUser("bella"); err != nil {
+		t.Fatalf("Error revoking user: %v", err)
+	}
+	if sessions, _ = index.ListByUser("bella"); len(sessions) != 0 {
+		t.Errorf("Expected no session of bella after revoking; Got %d", len(sessions))
+	}
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: other.GetID()})
+	if session, _ := store.Get(req, "session-key"); !session.IsNew() {
+		t.Error("Expected revoked session to be deleted")
+	}
+
+	// Deleted sessions leave the index.
+	_ = store.Delete(laptop)
+	if sessions, _ = index.ListByUser("coco"); len(sessions) != 0 {
+		t.Errorf("Expected no session of coco after deleting; Got %d", len(sessions))
+	}
+}
+
+func TestMemoryStore_Inspector(t *testing.T) {
+	store, _ := NewMemoryStore()
+	inspector := store.(Inspector)
+
+	ids := make(map[string]bool)
+	for i := 0; i < 5; i++ {
+		session, _ := store.New("session-key")
+		ids[session.GetID()] = true
+	}
+	if n, _ := inspector.Count(); n != 5 {
+		t.Fatalf("Expected 5 sessions; Got %d", n)
+	}
+
+	// Page through all sessions.
+	listed := make(map[string]bool)
+	cursor := ""
+	for {
+		sessions, next, err := inspector.List(cursor, 2)
+		if err != nil {
+			t.Fatalf("Error listing sessions: %v", err)
+		}
+		for _, session := range sessions {
+			listed[session.GetID()] = true
+		}
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	if len(listed) != len(ids) {
+		t.Errorf("Expected to list %d sessions; Got %d", len(ids), len(listed))
+	}
+
+	for id := range ids {
+		if _, err := inspector.Load(id); err != nil {
+			t.Errorf("Error loading session: %v", err)
+		}
+		if err := inspector.DeleteByID(id); err != nil {
+			t.Errorf("Error deleting session: %v", err)
+		}
+		if _, err := inspector.Load(id); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound; Got %v", err)
+		}
+		break
+	}
+}
+
+func TestMemoryStore_Incr(t *testing.T) {
+	store, _ := NewMemoryStore()
+	counter := store.(Counter)
+	session, _ := store.New("session-key")
+
+	done := make(chan struct{})
+	for i := 0; i < 50; i++ {
+		go func() {
+			if _, err := counter.Incr(session, "visits", 2); err != nil {
+				t.Errorf("Incr failed: %v", err)
+			}
+			done <- struct{}{}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		<-done
+	}
+	if got := session.GetValueByKey("visits"); got != int64(100) {
+		t.Errorf("Expected 100 visits; Got %v", got)
+	}
+
+	_ = store.Delete(session)
+	if _, err := counter.Incr(session, "visits", 1); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound; Got %v", err)
+	}
+}
+
+func TestMemoryStore_ListSkipsExpired(t *testing.T) {
+	store, _ := NewMemoryStore(WithGCInterval(time.Hour))
+	inspector := store.(Inspector)
+	live, _ := store.New("session-key")
+	expired, _ := store.New("session-key")
+	expired.SetMaxAge(-1)
+
+	sessions, _, err := inspector.List("", 10)
+	if err != nil {
+		t.Fatalf("Error listing sessions: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0] != live {
+		t.Errorf("Expected only the live session; Got %d sessions", len(sessions))
+	}
+}
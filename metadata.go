@@ -0,0 +1,115 @@
+package sessions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// touch records the access time and the client of the request into the session,
+// and binds the session to the client if it isn't bound yet.
+// It does nothing if r is nil, e.g. when a session is created by Store.New.
+func (b *baseStore) touch(session *Session, r *http.Request) {
+	if r == nil {
+		return
+	}
+	ip := clientIP(r, b.trustedProxies)
+	uaHash := hashUserAgent(r.UserAgent())
+
+	session.mutex.Lock()
+	defer session.mutex.Unlock()
+	session.data.LastAccessedAt = time.Now().Unix()
+	session.data.IP = ip
+	session.data.UserAgentHash = uaHash
+	if b.fingerprint != nil && session.data.Fingerprint == "" {
+		session.data.Fingerprint = b.fingerprint.fingerprint(r, b.trustedProxies)
+	}
+}
+
+// clientIP returns the IP address of the client who sent the request.
+// X-Forwarded-For is only honored when the request comes from a trusted proxy,
+// in which case the right-most address that isn't a trusted proxy is the client.
+func clientIP(r *http.Request, trustedProxies []*net.IPNet) string {
+	remote := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		remote = host
+	}
+	if !isTrustedProxy(remote, trustedProxies) {
+		return remote
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	if len(hops) == 0 {
+		if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+			return realIP
+		}
+		return remote
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		if !isTrustedProxy(hops[i], trustedProxies) {
+			return hops[i]
+		}
+	}
+	// Every hop is a trusted proxy, the left-most one is the closest to the client.
+	return hops[0]
+}
+
+func isTrustedProxy(addr string, trustedProxies []*net.IPNet) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseCIDRs parses proxy addresses in CIDR notation,
+// a plain IP address is treated as a network with a single address.
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address: %s", cidr)
+			}
+			bits := 8 * net.IPv4len
+			if ip.To4() == nil {
+				bits = 8 * net.IPv6len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy address: %w", err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// hashUserAgent returns the hex encoded SHA-256 of the User-Agent,
+// the raw User-Agent is not stored to keep the session small.
+func hashUserAgent(userAgent string) string {
+	if userAgent == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(userAgent))
+	return hex.EncodeToString(sum[:])
+}
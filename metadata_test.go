@@ -0,0 +1,62 @@
+package sessions
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIP(t *testing.T) {
+	trusted, err := parseCIDRs([]string{"10.0.0.0/8", "192.168.1.1"})
+	assert.NoError(t, err)
+
+	testCases := []struct {
+		remoteAddr   string
+		forwardedFor string
+		expectedIP   string
+	}{
+		// untrusted peer, X-Forwarded-For is ignored
+		{remoteAddr: "203.0.113.7:1234", forwardedFor: "198.51.100.1", expectedIP: "203.0.113.7"},
+		// trusted peer, the client is the right-most untrusted hop
+		{remoteAddr: "10.0.0.2:1234", forwardedFor: "198.51.100.1, 203.0.113.9, 192.168.1.1", expectedIP: "203.0.113.9"},
+		// trusted peer without X-Forwarded-For
+		{remoteAddr: "10.0.0.2:1234", expectedIP: "10.0.0.2"},
+		// every hop is trusted
+		{remoteAddr: "10.0.0.2:1234", forwardedFor: "10.1.1.1, 192.168.1.1", expectedIP: "10.1.1.1"},
+	}
+	for i, tc := range testCases {
+		req := httptest.NewRequest("GET", "http://example.com", nil)
+		req.RemoteAddr = tc.remoteAddr
+		if tc.forwardedFor != "" {
+			req.Header.Set("X-Forwarded-For", tc.forwardedFor)
+		}
+		assert.Equal(t, tc.expectedIP, clientIP(req, trusted), "test case: %d", i)
+	}
+
+	_, err = parseCIDRs([]string{"not-an-ip"})
+	assert.Error(t, err)
+}
+
+func TestMemoryStore_CapturesMetadata(t *testing.T) {
+	store, _ := NewMemoryStore(WithTrustedProxies("10.0.0.0/8"))
+
+	req := httptest.NewRequest("GET", "http://example.com", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.9")
+	req.Header.Set("User-Agent", "curl/8.0")
+	session, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+
+	assert.Equal(t, "203.0.113.9", session.GetIP())
+	assert.Equal(t, hashUserAgent("curl/8.0"), session.GetUserAgentHash())
+	assert.False(t, session.GetCreatedAt().IsZero())
+	assert.False(t, session.GetLastAccessedAt().Before(session.GetCreatedAt()))
+
+	data, err := (&Serializer{}).Serialize(session)
+	assert.NoError(t, err)
+	loaded := &Session{}
+	assert.NoError(t, (&Serializer{}).Deserialize(data, loaded))
+	assert.Equal(t, session.GetIP(), loaded.GetIP())
+	assert.Equal(t, session.GetCreatedAt(), loaded.GetCreatedAt())
+}
@@ -0,0 +1,51 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Migrate copies every live session from src into dst and returns how many were copied.
+// Sessions keep their IDs and expiry times, so users stay logged in,
+// and user bindings are kept if dst implements UserIndex.
+// src must implement Scanner.
+func Migrate(ctx context.Context, src, dst Store) (int, error) {
+	scanner, ok := src.(Scanner)
+	if !ok {
+		return 0, errors.New("sessions: source store doesn't support iteration")
+	}
+	index, _ := dst.(UserIndex)
+
+	migrated := 0
+	var saveErr error
+	err := scanner.Range(ctx, func(session *Session) bool {
+		if ctx.Err() != nil {
+			saveErr = ctx.Err()
+			return false
+		}
+		session.mutex.RLock()
+		expiry, userID := session.data.Expiry, session.data.UserID
+		session.mutex.RUnlock()
+		if expiry <= time.Now().Unix() {
+			return true
+		}
+
+		if userID != "" && index != nil {
+			saveErr = index.BindUser(session, userID)
+		} else {
+			saveErr = dst.Save(session)
+		}
+		if saveErr != nil {
+			saveErr = fmt.Errorf("failed to migrate session: %w", saveErr)
+			return false
+		}
+		migrated++
+		return true
+	})
+	if err != nil {
+		return migrated, err
+	}
+	return migrated, saveErr
+}
@@ -0,0 +1,55 @@
+package sessions
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrate(t *testing.T) {
+	src, _ := NewMemoryStore()
+	dst, _ := NewMemoryStore()
+
+	session, _ := src.New("session-key")
+	session.SetValue("name", "Coco")
+	session.SetMaxAge(120)
+	_ = src.(UserIndex).BindUser(session, "coco")
+	expired, _ := src.New("session-key")
+	expired.data.Expiry = time.Now().Unix() - 1
+
+	n, err := Migrate(context.Background(), src, dst)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: session.GetID()})
+	migrated, err := dst.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.False(t, migrated.IsNew())
+	assert.Equal(t, "Coco", migrated.GetValueByKey("name"))
+	sessions, _ := dst.(UserIndex).ListByUser("coco")
+	assert.Len(t, sessions, 1)
+
+	// Stores without Range can't be migrated from.
+	_, err = Migrate(context.Background(), struct{ Store }{src}, dst)
+	assert.Error(t, err)
+}
+
+func TestMigrate_KeepsTTLInRedis(t *testing.T) {
+	src, _ := NewFileStore(t.TempDir())
+	client := setupRedisClient()
+	dst, _ := NewRedisStore(client)
+
+	session, _ := src.New("migrate_session")
+	session.data.Expiry = time.Now().Add(30 * time.Second).Unix()
+	assert.NoError(t, src.Save(session))
+
+	_, err := Migrate(context.Background(), src, dst)
+	assert.NoError(t, err)
+	ttl, err := client.TTL(context.Background(), session.GetID()).Result()
+	assert.NoError(t, err)
+	assert.InDelta(t, 30*time.Second, ttl, float64(2*time.Second))
+}
@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/redis/go-redis/v9"
@@ -14,7 +16,17 @@ import (
 type RedisStore struct {
 	*baseStore
 	client     *redis.Client
-	serializer *Serializer
+	serializer Codec
+	keyPrefix  string // prefix of all keys written by the store, empty by default
+
+	expiryEvents bool // emit EventExpired from Redis keyspace notifications
+
+	failurePolicy FailurePolicy
+	fallback      *MemoryStore // holds sessions while Redis is unavailable, FailLocal only
+	health        *redisHealth
+
+	corruptPolicy CorruptPolicy
+	onDecodeError func(id string, err error)
 }
 
 // NewRedisStore creates a new RedisStore with the given Redis client and options.
@@ -28,88 +40,617 @@ func NewRedisStore(client *redis.Client, options ...func(*RedisStore)) (Store, e
 		baseStore:  base,
 		client:     client,
 		serializer: &Serializer{},
+		health:     &redisHealth{},
 	}
 
 	for _, op := range options {
 		op(store)
 	}
 
+	if store.failurePolicy == FailLocal {
+		store.fallback = store.newFallback()
+	}
+
+	if store.expiryEvents {
+		channel := fmt.Sprintf("__keyevent@%d__:expired", client.Options().DB)
+		pubsub := client.Subscribe(context.Background(), channel)
+		if _, err := pubsub.Receive(context.Background()); err != nil {
+			_ = pubsub.Close()
+			return nil, fmt.Errorf("failed to subscribe to keyspace notifications: %w", redisError(err))
+		}
+		go store.watchExpiry(pubsub.Channel())
+	}
+
 	return store, nil
 }
 
+// watchExpiry emits EventExpired for every session key expired by Redis.
+func (s *RedisStore) watchExpiry(messages <-chan *redis.Message) {
+	for msg := range messages {
+		if !strings.HasPrefix(msg.Payload, s.keyPrefix) {
+			continue
+		}
+		// Skips the sets of users, they aren't sessions.
+		if id := strings.TrimPrefix(msg.Payload, s.keyPrefix); isSessionIDValid(id) {
+			s.emitID(EventExpired, id, "")
+		}
+	}
+}
+
 // generateID generates a unique session ID.
 // TODO: 避免无限循环, 限制最大尝试次数
-func (s *RedisStore) generateID() (string, error) {
+func (s *RedisStore) generateID(ctx context.Context) (string, error) {
 	for {
 		id, err := generateRandomID(s.idLength)
 		if err != nil {
 			return "", err
 		}
-		exists, err := s.client.Exists(context.Background(), id).Result()
+		exists, err := s.client.Exists(ctx, s.key(id)).Result()
 		if err != nil {
-			return "", err
+			return "", redisError(err)
 		}
 		if exists == 0 {
 			return id, nil
 		}
+		s.logCollision(id)
 	}
 }
 
 // Get retrieves a session by name from the Redis store or creates a new one.
+// If Redis is unavailable, the failure policy of the store decides what is returned.
 func (s *RedisStore) Get(r *http.Request, name string) (*Session, error) {
 	if !isCookieNameValid(name) {
-		return nil, fmt.Errorf("sessions: invalid character in cookie name: %s", name)
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCookieName, name)
+	}
+
+	var session *Session
+	err := s.guard(func() (err error) {
+		session, err = s.get(r, name)
+		return err
+	})
+	if errors.Is(err, ErrStoreUnavailable) && s.failurePolicy != FailClosed {
+		return s.degradedGet(r, name)
 	}
+	return session, err
+}
 
+// get retrieves a session by name from Redis or creates a new one.
+func (s *RedisStore) get(r *http.Request, name string) (*Session, error) {
+	// Cookies are forged easily, their value mustn't name any other key.
 	cookie, err := r.Cookie(name)
-	if err != nil {
-		return s.New(name)
+	if err != nil || !isSessionIDValid(cookie.Value) {
+		return s.create(r.Context(), r, name)
 	}
 
-	sessionID := cookie.Value
-	data, err := s.client.Get(context.Background(), sessionID).Result()
-	if err != nil && errors.Is(err, redis.Nil) {
-		return s.New(name)
+	session, err := s.load(r.Context(), cookie.Value)
+	if errors.Is(err, ErrNotFound) {
+		// The session may have been created while Redis was unavailable.
+		if session = s.fromFallback(cookie.Value); session == nil {
+			s.emitID(EventMissed, cookie.Value, name)
+			return s.create(r.Context(), r, name)
+		}
+	} else if errors.Is(err, ErrDecode) && s.corruptPolicy == CorruptReplace {
+		// Otherwise every request with the cookie fails until the key expires.
+		// Without a key prefix the key may not be a session, so it's left to expire.
+		if s.keyPrefix != "" {
+			deleted, err := s.client.Del(r.Context(), s.key(cookie.Value)).Result()
+			if err != nil {
+				return nil, redisError(err)
+			}
+			if deleted > 0 {
+				s.emitID(EventDestroyed, cookie.Value, name)
+			}
+		}
+		return s.create(r.Context(), r, name)
 	} else if err != nil {
 		return nil, err
 	}
-
-	session := &Session{}
-	err = s.serializer.Deserialize([]byte(data), session)
-	if err != nil {
-		return nil, err
+	if !s.verifyFingerprint(session, r) {
+		if !s.fingerprint.Invalidate {
+			return nil, ErrSessionHijackSuspected
+		}
+		if err := s.delete(r.Context(), session); err != nil {
+			return nil, err
+		}
+		return s.create(r.Context(), r, name)
 	}
 	session.data.IsNew = false
+	s.touch(session, r)
+	s.emit(EventLoaded, session)
 	return session, nil
 }
 
 // New creates a new session and saves it in the Redis store.
+// If Redis is unavailable, the failure policy of the store decides what is returned.
 func (s *RedisStore) New(name string) (*Session, error) {
-	id, err := s.generateID()
+	return s.newContext(context.Background(), name)
+}
+
+// newContext is New sending its commands with ctx.
+func (s *RedisStore) newContext(ctx context.Context, name string) (*Session, error) {
+	var session *Session
+	err := s.guard(func() (err error) {
+		session, err = s.create(ctx, nil, name)
+		return err
+	})
+	if errors.Is(err, ErrStoreUnavailable) && s.failurePolicy != FailClosed {
+		return s.degradedNew(name)
+	}
+	return session, err
+}
+
+// create creates a new session for the request r and saves it in the Redis store,
+// r may be nil if the session isn't created for a request.
+func (s *RedisStore) create(ctx context.Context, r *http.Request, name string) (*Session, error) {
+	id, err := s.generateID(ctx)
 	if err != nil {
 		return nil, err
 	}
 
 	session := NewSession(name, id, *s.options)
-	err = s.Save(session)
+	s.touch(session, r)
+	err = s.save(ctx, session)
 	if err != nil {
 		return nil, err
 	}
 
+	s.emit(EventCreated, session)
 	return session, nil
 }
 
 // Save persists the session in the Redis store.
+// If Redis is unavailable, the failure policy of the store decides whether the error is returned.
 func (s *RedisStore) Save(session *Session) error {
+	return s.saveContext(context.Background(), session)
+}
+
+// saveContext is Save sending its commands with ctx.
+func (s *RedisStore) saveContext(ctx context.Context, session *Session) error {
+	err := s.guard(func() error {
+		return s.save(ctx, session)
+	})
+	if errors.Is(err, ErrStoreUnavailable) && s.failurePolicy != FailClosed {
+		return s.degradedSave(session, err)
+	}
+	return err
+}
+
+// save writes the session to Redis.
+func (s *RedisStore) save(ctx context.Context, session *Session) error {
 	data, err := s.serializer.Serialize(session)
 	if err != nil {
 		return err
 	}
-	expiration := session.data.Options.MaxAge * time.Second
-	return s.client.Set(context.Background(), session.data.ID, data, expiration).Err()
+	s.observeSize(len(data))
+	if err := s.checkLimits(session, data); err != nil {
+		return err
+	}
+	expiration := s.expiration(session)
+	userID := session.GetUserID()
+	removed := session.removedCounterKeys()
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.key(session.data.ID), data, expiration)
+		if userID != "" {
+			bindUserScript.Eval(ctx, pipe, []string{s.userKey(userID)}, session.data.ID, expiration.Milliseconds())
+		}
+		// Otherwise load merges the counters removed by Session.Delete or Clear back.
+		if len(removed) > 0 {
+			pipe.HDel(ctx, s.countersKey(session.data.ID), removed...)
+		}
+		s.expireCounters(ctx, pipe, session.data.ID, expiration)
+		return nil
+	})
+	if err != nil {
+		return redisError(err)
+	}
+	session.forgetRemovedCounters(removed)
+	s.forget(session)
+	s.emit(EventSaved, session)
+	return nil
+}
+
+// expiration returns how long Redis keeps the session: the time left until it expires,
+// so sessions copied from another store keep their TTL.
+// A MaxAge of 0 keeps the session until it's deleted.
+func (s *RedisStore) expiration(session *Session) time.Duration {
+	session.mutex.RLock()
+	defer session.mutex.RUnlock()
+	if session.data.Options.MaxAge == 0 {
+		return 0
+	}
+	if session.data.Expiry == 0 {
+		return session.data.Options.MaxAge * time.Second
+	}
+	expiration := time.Until(time.Unix(session.data.Expiry, 0))
+	if expiration < time.Millisecond {
+		// Already expired, a non-positive expiration would keep it forever.
+		expiration = time.Millisecond
+	}
+	return expiration
 }
 
 // Delete removes the session from the Redis store.
+// Deleting a session, e.g. on logout, must not look successful while it's still in Redis,
+// so errors are returned whatever the failure policy.
 func (s *RedisStore) Delete(session *Session) error {
-	return s.client.Del(context.Background(), session.data.ID).Err()
+	return s.deleteContext(context.Background(), session)
+}
+
+// deleteContext is Delete sending its commands with ctx.
+func (s *RedisStore) deleteContext(ctx context.Context, session *Session) error {
+	s.forget(session)
+	return s.guard(func() error {
+		return s.delete(ctx, session)
+	})
+}
+
+// delete removes the session from Redis, EventDestroyed is only emitted if the key existed.
+func (s *RedisStore) delete(ctx context.Context, session *Session) error {
+	userID := session.GetUserID()
+	var del *redis.IntCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		del = pipe.Del(ctx, s.key(session.data.ID))
+		pipe.Del(ctx, s.countersKey(session.data.ID))
+		if userID != "" {
+			pipe.SRem(ctx, s.userKey(userID), session.data.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return redisError(err)
+	}
+	if del.Val() > 0 {
+		s.emit(EventDestroyed, session)
+	}
+	return nil
+}
+
+// bindUserScript adds a session ID to the set of a user,
+// and makes sure the set lives at least as long as the session.
+// It runs inside a transaction, so it is sent with EVAL rather than EVALSHA.
+// A TTL of 0 means the session never expires, so neither does the set.
+var bindUserScript = redis.NewScript(`
+local existed = redis.call('EXISTS', KEYS[1]) == 1
+redis.call('SADD', KEYS[1], ARGV[1])
+local ttl = tonumber(ARGV[2])
+if ttl <= 0 then
+	redis.call('PERSIST', KEYS[1])
+	return 1
+end
+local current = redis.call('PTTL', KEYS[1])
+if not existed or (current >= 0 and current < ttl) then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+end
+return 1
+`)
+
+// key returns the key of the session with the id.
+func (s *RedisStore) key(id string) string {
+	return s.keyPrefix + id
+}
+
+// keys returns the keys of the sessions with the ids.
+func (s *RedisStore) keys(ids []string) []string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = s.key(id)
+	}
+	return keys
+}
+
+// countersKey returns the key of the hash that holds the counters of a session, see Incr.
+// Session IDs never contain ':', so it can't collide with a session key.
+func (s *RedisStore) countersKey(id string) string {
+	return s.keyPrefix + id + ":counters"
+}
+
+// userKey returns the key of the set that holds the session IDs of a user.
+// Session IDs never contain ':', so it can't collide with a session key.
+func (s *RedisStore) userKey(userID string) string {
+	return s.keyPrefix + "user:" + userID
+}
+
+// load reads the session with the id and merges its counters,
+// it returns ErrNotFound if there is no such session.
+func (s *RedisStore) load(ctx context.Context, id string) (*Session, error) {
+	if !isSessionIDValid(id) {
+		return nil, ErrNotFound
+	}
+	var get *redis.StringCmd
+	var counters *redis.MapStringStringCmd
+	// The pipeline fails with redis.Nil when GET doesn't find the session.
+	_, err := s.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, s.key(id))
+		counters = pipe.HGetAll(ctx, s.countersKey(id))
+		return nil
+	})
+	data := get.Val()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrNotFound
+	} else if err != nil {
+		s.logLoadError(id, err)
+		return nil, redisError(err)
+	}
+
+	session, err := decodeSession(s.serializer, []byte(data))
+	if err != nil {
+		s.logDecodeError(id, err)
+		if s.onDecodeError != nil {
+			s.onDecodeError(id, err)
+		}
+		return nil, err
+	}
+	session.mutex.Lock()
+	for key, value := range counters.Val() {
+		if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+			session.data.Values[key] = n
+		}
+	}
+	session.mutex.Unlock()
+	return session, nil
+}
+
+// Incr increments the value of key in a hash next to the session with HINCRBY,
+// so concurrent increments aren't lost. Counters are merged into the values of the session when it's loaded,
+// and expire with it. Sessions returned by Range, List and ListByUser don't include counters
+// incremented since they were last saved.
+// If Redis is unavailable and the failure policy isn't FailClosed, the value is incremented in the session and saved.
+func (s *RedisStore) Incr(session *Session, key string, delta int64) (int64, error) {
+	ctx := context.Background()
+	id, expiration := session.GetID(), s.expiration(session)
+	if !isSessionIDValid(id) {
+		return 0, ErrInvalidSessionID
+	}
+	removed := session.isCounterRemoved(key)
+	var incr *redis.IntCmd
+	err := s.guard(func() error {
+		_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// A counter removed by Session.Delete or Clear starts again from 0.
+			if removed {
+				pipe.HDel(ctx, s.countersKey(id), key)
+			}
+			incr = pipe.HIncrBy(ctx, s.countersKey(id), key, delta)
+			s.expireCounters(ctx, pipe, id, expiration)
+			return nil
+		})
+		return redisError(err)
+	})
+	if errors.Is(err, ErrStoreUnavailable) && s.failurePolicy != FailClosed {
+		n := session.Incr(key, delta)
+		return n, s.degradedSave(session, err)
+	} else if err != nil {
+		return 0, err
+	}
+	session.setCounter(key, incr.Val())
+	return incr.Val(), nil
+}
+
+// expireCounters makes the counters of a session expire with it, an expiration of 0 never expires.
+func (s *RedisStore) expireCounters(ctx context.Context, pipe redis.Pipeliner, id string, expiration time.Duration) {
+	if expiration > 0 {
+		pipe.PExpire(ctx, s.countersKey(id), expiration)
+	} else {
+		pipe.Persist(ctx, s.countersKey(id))
+	}
+}
+
+// BindUser binds the session to the user and saves it.
+func (s *RedisStore) BindUser(session *Session, userID string) error {
+	if userID == "" {
+		return ErrInvalidUserID
+	}
+	session.mutex.Lock()
+	oldUserID := session.data.UserID
+	session.data.UserID = userID
+	session.mutex.Unlock()
+
+	if oldUserID != "" && oldUserID != userID {
+		if err := s.client.SRem(context.Background(), s.userKey(oldUserID), session.data.ID).Err(); err != nil {
+			return redisError(err)
+		}
+	}
+	return s.Save(session)
+}
+
+// ListByUser returns the live sessions bound to the user.
+// IDs of sessions that have expired are removed from the set of the user.
+func (s *RedisStore) ListByUser(userID string) ([]*Session, error) {
+	ctx := context.Background()
+	ids, err := s.client.SMembers(ctx, s.userKey(userID)).Result()
+	if err != nil || len(ids) == 0 {
+		return nil, redisError(err)
+	}
+	values, err := s.client.MGet(ctx, s.keys(ids)...).Result()
+	if err != nil {
+		return nil, redisError(err)
+	}
+
+	var sessions []*Session
+	var stale []interface{}
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			stale = append(stale, ids[i])
+			continue
+		}
+		session, err := decodeSession(s.serializer, []byte(data))
+		if err != nil {
+			return nil, err
+		}
+		session.data.IsNew = false
+		sessions = append(sessions, session)
+	}
+	if len(stale) > 0 {
+		if err := s.client.SRem(ctx, s.userKey(userID), stale...).Err(); err != nil {
+			return nil, redisError(err)
+		}
+	}
+	return sessions, nil
+}
+
+// RevokeUser deletes all sessions bound to the user.
+func (s *RedisStore) RevokeUser(userID string) error {
+	ctx := context.Background()
+	key := s.userKey(userID)
+	ids, err := s.client.SMembers(ctx, key).Result()
+	if err != nil {
+		return redisError(err)
+	}
+	dels := make([]*redis.IntCmd, len(ids))
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			dels[i] = pipe.Del(ctx, s.key(id))
+			pipe.Del(ctx, s.countersKey(id))
+		}
+		pipe.Del(ctx, key)
+		return nil
+	})
+	if err != nil {
+		return redisError(err)
+	}
+	// The set may still hold the IDs of expired sessions.
+	for i, id := range ids {
+		if dels[i].Val() > 0 {
+			s.emitID(EventDestroyed, id, "")
+		}
+	}
+	return nil
+}
+
+// Range calls fn for every session in the Redis store until fn returns false.
+// Keys are scanned in batches, keys that can't be session IDs of the store, i.e. IDs of another length,
+// and values that can't be decoded are skipped.
+// Without WithRedisKeyPrefix every key of the database is scanned, so give the store a prefix
+// if the database holds other keys.
+func (s *RedisStore) Range(ctx context.Context, fn func(session *Session) bool) error {
+	var cursor uint64
+	for {
+		sessions, next, err := s.scan(ctx, cursor, 100)
+		if err != nil {
+			return err
+		}
+		for _, session := range sessions {
+			if !fn(session) {
+				return nil
+			}
+		}
+		if next == 0 {
+			return nil
+		}
+		cursor = next
+	}
+}
+
+// scan runs one SCAN iteration over the keys with the prefix of the store
+// and returns the sessions found and the cursor of the next iteration, 0 when done.
+func (s *RedisStore) scan(ctx context.Context, cursor uint64, count int64) ([]*Session, uint64, error) {
+	keys, next, err := s.client.Scan(ctx, cursor, s.keyPrefix+"*", count).Result()
+	if err != nil {
+		return nil, 0, redisError(err)
+	}
+	var ids []string
+	for _, key := range keys {
+		if id, ok := s.scannedID(key); ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil, next, nil
+	}
+
+	values, err := s.client.MGet(ctx, s.keys(ids)...).Result()
+	if err != nil {
+		return nil, 0, redisError(err)
+	}
+	sessions := make([]*Session, 0, len(values))
+	for i, value := range values {
+		// The key expired since it was scanned, or isn't a string.
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+		// A corrupt session, or a key that isn't a session, mustn't stop the scan.
+		session, err := decodeSession(s.serializer, []byte(data))
+		if err != nil {
+			s.logDecodeError(ids[i], err)
+			continue
+		}
+		session.data.IsNew = false
+		sessions = append(sessions, session)
+	}
+	return sessions, next, nil
+}
+
+// scannedID returns the session ID of a key found by SCAN, ok is false if the key can't be
+// the key of a session generated by the store, e.g. a counters key or a key of another application.
+func (s *RedisStore) scannedID(key string) (id string, ok bool) {
+	id = strings.TrimPrefix(key, s.keyPrefix)
+	return id, len(id) == s.idLength && isSessionIDValid(id)
+}
+
+// Count returns the number of sessions in the Redis store, it scans all keys with the prefix of the store
+// and counts those which can be session IDs of the store, i.e. valid IDs of its ID length.
+// Keys aren't read, so without WithRedisKeyPrefix other keys that look like session IDs are counted as well.
+func (s *RedisStore) Count() (int, error) {
+	ctx := context.Background()
+	count := 0
+	var cursor uint64
+	for {
+		keys, next, err := s.client.Scan(ctx, cursor, s.keyPrefix+"*", 1000).Result()
+		if err != nil {
+			return 0, redisError(err)
+		}
+		for _, key := range keys {
+			if _, ok := s.scannedID(key); ok {
+				count++
+			}
+		}
+		if next == 0 {
+			return count, nil
+		}
+		cursor = next
+	}
+}
+
+// List returns a page of sessions and the cursor of the next page, which is empty after the last page.
+// The cursor is a Redis SCAN cursor and limit is passed as its COUNT hint,
+// so a page may hold a few more or fewer sessions than limit.
+func (s *RedisStore) List(cursor string, limit int) ([]*Session, string, error) {
+	var start uint64
+	if cursor != "" {
+		var err error
+		if start, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return nil, "", fmt.Errorf("sessions: invalid cursor: %s", cursor)
+		}
+	}
+	if limit <= 0 {
+		return nil, "", errors.New("sessions: limit must be positive")
+	}
+	sessions, next, err := s.scan(context.Background(), start, int64(limit))
+	if err != nil || next == 0 {
+		return sessions, "", err
+	}
+	return sessions, strconv.FormatUint(next, 10), nil
+}
+
+// Load returns the session with the id, or ErrNotFound.
+func (s *RedisStore) Load(id string) (*Session, error) {
+	if !isSessionIDValid(id) {
+		return nil, ErrNotFound
+	}
+	session, err := s.load(context.Background(), id)
+	if err != nil {
+		return nil, err
+	}
+	session.data.IsNew = false
+	return session, nil
+}
+
+// DeleteByID deletes the session with the id, or returns ErrNotFound.
+func (s *RedisStore) DeleteByID(id string) error {
+	session, err := s.Load(id)
+	if err != nil {
+		return err
+	}
+	return s.Delete(session)
 }
@@ -3,6 +3,7 @@ package sessions
 import (
 	"context"
 	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -49,3 +50,238 @@ func TestRedisStore_New(t *testing.T) {
 	assert.NoError(t, err)
 	assert.NotEmpty(t, data)
 }
+
+func TestRedisStore_UserIndex(t *testing.T) {
+	client := setupRedisClient()
+	store, _ := NewRedisStore(client)
+	index := store.(UserIndex)
+
+	session, _ := store.New("user_session")
+	assert.NoError(t, index.BindUser(session, "test_user"))
+
+	sessions, err := index.ListByUser("test_user")
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	assert.Equal(t, session.GetID(), sessions[0].GetID())
+	// 新建的 set 也要设置过期时间
+	ttl, _ := client.PTTL(context.Background(), "user:test_user").Result()
+	assert.Greater(t, ttl, time.Duration(0))
+
+	// 会话过期后, ListByUser 会清理 set 中失效的 ID
+	client.Del(context.Background(), session.GetID())
+	sessions, err = index.ListByUser("test_user")
+	assert.NoError(t, err)
+	assert.Empty(t, sessions)
+
+	other, _ := store.New("user_session")
+	assert.NoError(t, index.BindUser(other, "test_user"))
+	assert.NoError(t, index.RevokeUser("test_user"))
+	exists, _ := client.Exists(context.Background(), other.GetID(), "user:test_user").Result()
+	assert.Equal(t, int64(0), exists)
+}
+
+func TestRedisStore_Inspector(t *testing.T) {
+	client := setupRedisClient()
+	store, _ := NewRedisStore(client, WithRedisKeyPrefix("inspector:"))
+	inspector := store.(Inspector)
+
+	// 清理上次测试留下的 key
+	keys, _ := client.Keys(context.Background(), "inspector:*").Result()
+	if len(keys) > 0 {
+		client.Del(context.Background(), keys...)
+	}
+	// 不带前缀的 key 不会被统计
+	client.Set(context.Background(), "other_key", "value", time.Minute)
+
+	session, _ := store.New("inspector_session")
+	_, _ = store.New("inspector_session")
+	_ = store.(UserIndex).BindUser(session, "inspector_user")
+
+	count, err := inspector.Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count)
+
+	var listed []*Session
+	cursor := ""
+	for {
+		sessions, next, err := inspector.List(cursor, 1)
+		assert.NoError(t, err)
+		listed = append(listed, sessions...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+	assert.Len(t, listed, 2)
+
+	loaded, err := inspector.Load(session.GetID())
+	assert.NoError(t, err)
+	assert.Equal(t, "inspector_user", loaded.GetUserID())
+
+	assert.NoError(t, inspector.DeleteByID(session.GetID()))
+	_, err = inspector.Load(session.GetID())
+	assert.ErrorIs(t, err, ErrNotFound)
+	assert.ErrorIs(t, inspector.DeleteByID(session.GetID()), ErrNotFound)
+	members, _ := client.SMembers(context.Background(), "inspector:user:inspector_user").Result()
+	assert.Empty(t, members)
+}
+
+func TestRedisStore_CorruptData(t *testing.T) {
+	client := setupRedisClient()
+	var decodeErrors []string
+	store, _ := NewRedisStore(client, WithRedisKeyPrefix("corrupt:"), WithRedisOnDecodeError(func(id string, err error) {
+		decodeErrors = append(decodeErrors, id)
+	}))
+
+	// 写入无法解析的数据, 模拟 schema 变更后的旧会话
+	id := "corruptsession01"
+	client.Set(context.Background(), "corrupt:"+id, "{not json", time.Minute)
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "corrupt_session", Value: id})
+
+	// 默认直接返回错误
+	_, err := store.Get(req, "corrupt_session")
+	assert.ErrorIs(t, err, ErrDecode)
+	assert.Equal(t, []string{id}, decodeErrors)
+
+	// CorruptReplace 会删除损坏的数据并创建新会话
+	store, _ = NewRedisStore(client, WithRedisKeyPrefix("corrupt:"), WithRedisCorruptPolicy(CorruptReplace))
+	session, err := store.Get(req, "corrupt_session")
+	assert.NoError(t, err)
+	assert.True(t, session.IsNew())
+	assert.NotEqual(t, id, session.GetID())
+	exists, _ := client.Exists(context.Background(), "corrupt:"+id).Result()
+	assert.Equal(t, int64(0), exists)
+
+	// 之后同一个 cookie 不会再报错
+	_, err = store.Get(req, "corrupt_session")
+	assert.NoError(t, err)
+	_ = store.Delete(session)
+}
+
+func TestRedisStore_ForgedCookieCannotDeleteKeys(t *testing.T) {
+	client := setupRedisClient()
+	store, _ := NewRedisStore(client, WithRedisCorruptPolicy(CorruptReplace))
+	ctx := context.Background()
+	// 数据库中的其他键, 伪造的 cookie 不能删除它们
+	client.Set(ctx, "config", "{not json", time.Minute)
+	client.Set(ctx, "configuration0001", "{not json", time.Minute)
+	defer client.Del(ctx, "config", "configuration0001")
+
+	for _, key := range []string{"config", "configuration0001"} {
+		req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+		req.AddCookie(&http.Cookie{Name: "forged_session", Value: key})
+		session, err := store.Get(req, "forged_session")
+		assert.NoError(t, err)
+		assert.True(t, session.IsNew())
+		exists, _ := client.Exists(ctx, key).Result()
+		assert.Equal(t, int64(1), exists, key)
+		_ = store.Delete(session)
+	}
+}
+
+func TestRedisStore_Incr(t *testing.T) {
+	client := setupRedisClient()
+	store, _ := NewRedisStore(client)
+	counter := store.(Counter)
+
+	session, _ := store.New("counter_session")
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "counter_session", Value: session.GetID()})
+
+	// 每个请求各自加载会话并自增, 不会丢失更新
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			loaded, err := store.Get(req, "counter_session")
+			if assert.NoError(t, err) {
+				_, err = counter.Incr(loaded, "cart_items", 1)
+				assert.NoError(t, err)
+				assert.NoError(t, store.Save(loaded))
+			}
+		}()
+	}
+	wg.Wait()
+
+	loaded, err := store.Get(req, "counter_session")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(20), loaded.GetValueByKey("cart_items"))
+	n, err := counter.Incr(loaded, "cart_items", -5)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(15), n)
+	assert.Equal(t, int64(15), loaded.GetValueByKey("cart_items"))
+
+	// 计数器与会话一起过期和删除
+	ttl, _ := client.TTL(context.Background(), session.GetID()+":counters").Result()
+	assert.Greater(t, ttl, time.Duration(0))
+	assert.NoError(t, store.Delete(loaded))
+	exists, _ := client.Exists(context.Background(), session.GetID()+":counters").Result()
+	assert.Equal(t, int64(0), exists)
+}
+
+func TestRedisStore_DestroyedOnlyWhenRemoved(t *testing.T) {
+	store, _ := NewRedisStore(setupRedisClient())
+	recorder := &eventRecorder{}
+	store.(EventSource).AddListener(recorder.listen)
+
+	session, _ := store.New("destroyed_session")
+	assert.NoError(t, store.Delete(session))
+	// 会话已经不存在, 例如已经过期
+	assert.NoError(t, store.Delete(session))
+	assert.Equal(t, 1, recorder.count(EventDestroyed))
+}
+
+func TestRedisStore_RangeSkipsUndecodableValues(t *testing.T) {
+	client := setupRedisClient()
+	store, _ := NewRedisStore(client, WithRedisKeyPrefix("range_skip:"))
+	session, _ := store.New("range_session")
+	defer store.Delete(session)
+	// 同一前缀下长度不同的键不是会话, 无法解码的会话不会中断扫描
+	client.Set(context.Background(), "range_skip:counter", "5", time.Minute)
+	client.Set(context.Background(), "range_skip:corruptsession01", "{", time.Minute)
+
+	var ids []string
+	assert.NoError(t, store.(Scanner).Range(context.Background(), func(s *Session) bool {
+		ids = append(ids, s.GetID())
+		return true
+	}))
+	assert.Equal(t, []string{session.GetID()}, ids)
+	sessions, _, err := store.(Inspector).List("", 100)
+	assert.NoError(t, err)
+	assert.Len(t, sessions, 1)
+	count, err := store.(Inspector).Count()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, count, "Count doesn't read values, the corrupt session is counted")
+}
+
+func TestRedisStore_DeleteCounter(t *testing.T) {
+	store, _ := NewRedisStore(setupRedisClient())
+	counter := store.(Counter)
+	session, _ := store.New("counter_session")
+	defer store.Delete(session)
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "counter_session", Value: session.GetID()})
+
+	_, _ = counter.Incr(session, "visits", 1)
+	_, _ = counter.Incr(session, "cart_items", 3)
+
+	// 删除的计数器在下一次请求中不会再出现
+	loaded, _ := store.Get(req, "counter_session")
+	loaded.Delete("visits")
+	assert.NoError(t, store.Save(loaded))
+	loaded, _ = store.Get(req, "counter_session")
+	assert.False(t, loaded.Has("visits"))
+	assert.Equal(t, int64(3), loaded.GetValueByKey("cart_items"))
+
+	// 清空后重新自增的计数器从 0 开始, 并且不会在保存时被删除
+	loaded.Clear()
+	n, err := counter.Incr(loaded, "cart_items", 1)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), n)
+	assert.NoError(t, store.Save(loaded))
+	loaded, _ = store.Get(req, "counter_session")
+	assert.Equal(t, []string{"cart_items"}, loaded.Keys())
+	assert.Equal(t, int64(1), loaded.GetValueByKey("cart_items"))
+}
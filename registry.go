@@ -0,0 +1,102 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// Registry caches the sessions fetched during a single request,
+// so several named sessions, possibly living in different stores,
+// can be loaded once and saved together.
+type Registry struct {
+	request  *http.Request
+	mutex    sync.Mutex
+	sessions map[string]sessionInfo
+}
+
+// sessionInfo records a session fetched through a Registry and the store it came from.
+type sessionInfo struct {
+	session *Session
+	store   Store
+	err     error
+}
+
+type registryKey struct{}
+
+// NewRegistry returns a new Registry for the given request.
+func NewRegistry(r *http.Request) *Registry {
+	return &Registry{
+		request:  r,
+		sessions: make(map[string]sessionInfo),
+	}
+}
+
+// GetRegistry returns the Registry attached to the request by WithRegistry or RegistryHandler.
+// If there is none, a new Registry is returned, which is not shared with later calls.
+func GetRegistry(r *http.Request) *Registry {
+	if registry, ok := r.Context().Value(registryKey{}).(*Registry); ok {
+		return registry
+	}
+	return NewRegistry(r)
+}
+
+// WithRegistry returns a shallow copy of r whose context carries a new Registry.
+func WithRegistry(r *http.Request) *http.Request {
+	registry := NewRegistry(r)
+	r = r.WithContext(context.WithValue(r.Context(), registryKey{}, registry))
+	registry.request = r
+	return r
+}
+
+// RegistryHandler attaches a Registry to every request before calling next,
+// so handlers can share it through GetRegistry.
+func RegistryHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, WithRegistry(r))
+	})
+}
+
+// Get returns the session named name from store.
+// Repeated calls with the same name return the cached *Session (and error).
+func (r *Registry) Get(store Store, name string) (*Session, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if info, ok := r.sessions[name]; ok {
+		return info.session, info.err
+	}
+	session, err := store.Get(r.request, name)
+	r.sessions[name] = sessionInfo{session: session, store: store, err: err}
+	return session, err
+}
+
+// SaveAll persists every session fetched through the registry into its store
+// and writes the session cookies to w.
+// It keeps going when a session fails to save and returns all errors joined.
+func (r *Registry) SaveAll(w http.ResponseWriter) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	names := make([]string, 0, len(r.sessions))
+	for name := range r.sessions {
+		names = append(names, name)
+	}
+	// Keep Set-Cookie headers in a stable order.
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		info := r.sessions[name]
+		if info.err != nil {
+			continue
+		}
+		if err := info.store.Save(info.session); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		info.session.Save(w)
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,57 @@
+package sessions
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRegistry_GetCachesSessions(t *testing.T) {
+	authStore, _ := NewMemoryStore()
+	prefsStore, _ := NewMemoryStore()
+
+	req := httptest.NewRequest("GET", "http://example.com", nil)
+	registry := NewRegistry(req)
+
+	auth, err := registry.Get(authStore, "auth")
+	assert.NoError(t, err)
+	again, err := registry.Get(authStore, "auth")
+	assert.NoError(t, err)
+	assert.Same(t, auth, again)
+
+	prefs, err := registry.Get(prefsStore, "prefs")
+	assert.NoError(t, err)
+	assert.NotSame(t, auth, prefs)
+
+	_, err = registry.Get(authStore, "bad name")
+	assert.Error(t, err)
+}
+
+func TestRegistry_SaveAll(t *testing.T) {
+	store, _ := NewMemoryStore()
+
+	handler := RegistryHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, _ := GetRegistry(r).Get(store, "auth")
+		auth.SetValue("user", "coco")
+		cart, _ := GetRegistry(r).Get(store, "cart")
+		cart.SetValue("items", 3)
+		assert.NoError(t, GetRegistry(r).SaveAll(w))
+	}))
+
+	rsp := httptest.NewRecorder()
+	handler.ServeHTTP(rsp, httptest.NewRequest("GET", "http://example.com", nil))
+
+	cookies := rsp.Result().Cookies()
+	assert.Len(t, cookies, 2)
+	assert.Equal(t, "auth", cookies[0].Name)
+	assert.Equal(t, "cart", cookies[1].Name)
+
+	req := httptest.NewRequest("GET", "http://example.com", nil)
+	req.AddCookie(cookies[0])
+	session, err := store.Get(req, "auth")
+	assert.NoError(t, err)
+	assert.False(t, session.IsNew())
+	assert.Equal(t, "coco", session.GetValueByKey("user"))
+}
@@ -0,0 +1,80 @@
+package sessions
+
+import (
+	"fmt"
+	"sync"
+)
+
+// Upgrade migrates the values of a session from one schema version to the next, in place.
+type Upgrade func(values map[string]interface{}) error
+
+var (
+	upgradesMutex sync.RWMutex
+	upgrades      []Upgrade // upgrades[v] migrates values from version v to v+1
+)
+
+// RegisterUpgrade registers the upgrade of session values from version `from` to `from+1`,
+// upgrades must be registered in order, starting at version 0, e.g. in init functions.
+//
+// The schema version of new sessions is the number of registered upgrades.
+// Stores upgrade older sessions when they read them, one version at a time,
+// and the upgraded values are written back the next time the session is saved.
+// Values read by stores are decoded from JSON, so numbers are float64 and structs are maps.
+func RegisterUpgrade(from int, upgrade Upgrade) {
+	upgradesMutex.Lock()
+	defer upgradesMutex.Unlock()
+	if from != len(upgrades) {
+		panic(fmt.Sprintf("sessions: upgrade from version %d registered out of order, expected version %d", from, len(upgrades)))
+	}
+	if upgrade == nil {
+		panic("sessions: upgrade cannot be nil")
+	}
+	upgrades = append(upgrades, upgrade)
+}
+
+// SchemaVersion returns the current schema version of session values.
+func SchemaVersion() int {
+	upgradesMutex.RLock()
+	defer upgradesMutex.RUnlock()
+	return len(upgrades)
+}
+
+// GetVersion returns the schema version of the session values.
+func (s *Session) GetVersion() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.data.Version
+}
+
+// decodeSession deserializes a stored session and upgrades it to the current schema version.
+func decodeSession(serializer Codec, data []byte) (*Session, error) {
+	session := &Session{}
+	if err := serializer.Deserialize(data, session); err != nil {
+		return nil, err
+	}
+	if err := session.upgrade(); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// upgrade runs the upgrades from the schema version of the session to the current one.
+// A failed upgrade leaves the session unusable, so its error wraps ErrDecode.
+func (s *Session) upgrade() error {
+	upgradesMutex.RLock()
+	pending := upgrades
+	upgradesMutex.RUnlock()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.data.Values == nil {
+		s.data.Values = make(map[string]interface{})
+	}
+	// Sessions written by a newer version of the application, e.g. during a rolling deploy, are left as is.
+	for ; s.data.Version < len(pending); s.data.Version++ {
+		if err := pending[s.data.Version](s.data.Values); err != nil {
+			return fmt.Errorf("%w: upgrading from schema version %d: %w", ErrDecode, s.data.Version, err)
+		}
+	}
+	return nil
+}
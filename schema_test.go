@@ -0,0 +1,63 @@
+package sessions
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resetUpgrades removes the upgrades registered by a test.
+func resetUpgrades(t *testing.T) {
+	t.Cleanup(func() {
+		upgradesMutex.Lock()
+		upgrades = nil
+		upgradesMutex.Unlock()
+	})
+}
+
+func TestRegisterUpgrade(t *testing.T) {
+	resetUpgrades(t)
+	store, _ := NewFileStore(t.TempDir())
+
+	// A session written before the schema changed.
+	old, _ := store.New("session-key")
+	old.SetValue("cart", float64(3))
+	assert.NoError(t, store.Save(old))
+	assert.Equal(t, 0, old.GetVersion())
+
+	RegisterUpgrade(0, func(values map[string]interface{}) error {
+		values["cart"] = map[string]interface{}{"items": values["cart"]}
+		return nil
+	})
+	RegisterUpgrade(1, func(values map[string]interface{}) error {
+		values["currency"] = "EUR"
+		return nil
+	})
+	assert.Equal(t, 2, SchemaVersion())
+	assert.Panics(t, func() { RegisterUpgrade(5, func(map[string]interface{}) error { return nil }) })
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: old.GetID()})
+	session, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.False(t, session.IsNew())
+	assert.Equal(t, 2, session.GetVersion())
+	assert.Equal(t, map[string]interface{}{"items": float64(3)}, session.GetValueByKey("cart"))
+	assert.Equal(t, "EUR", session.GetValueByKey("currency"))
+
+	created, _ := store.New("session-key")
+	assert.Equal(t, 2, created.GetVersion())
+}
+
+func TestRegisterUpgrade_Failure(t *testing.T) {
+	resetUpgrades(t)
+	RegisterUpgrade(0, func(map[string]interface{}) error {
+		return errors.New("unknown cart format")
+	})
+
+	data, _ := (&Serializer{}).Serialize(&Session{data: &sessionData{ID: "id"}})
+	_, err := decodeSession(&Serializer{}, data)
+	assert.ErrorIs(t, err, ErrDecode)
+}
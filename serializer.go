@@ -1,7 +1,19 @@
 package sessions
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"fmt"
+)
 
+// Codec converts sessions to and from the bytes persistent stores write,
+// e.g. to compress or encrypt them. Serializer is the default Codec.
+type Codec interface {
+	Serialize(session *Session) ([]byte, error)
+	// Deserialize decodes data into session, its errors should wrap ErrDecode.
+	Deserialize(data []byte, session *Session) error
+}
+
+// Serializer encodes sessions as JSON.
 type Serializer struct{}
 
 func (js *Serializer) Serialize(session *Session) ([]byte, error) {
@@ -18,5 +30,8 @@ func (js *Serializer) Deserialize(data []byte, session *Session) error {
 		session.data = &sessionData{}
 	}
 
-	return json.Unmarshal(data, session.data)
+	if err := json.Unmarshal(data, session.data); err != nil {
+		return fmt.Errorf("%w: %w", ErrDecode, err)
+	}
+	return nil
 }
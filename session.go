@@ -2,6 +2,7 @@ package sessions
 
 import (
 	"net/http"
+	"sort"
 	"sync"
 	"time"
 )
@@ -9,6 +10,13 @@ import (
 type Session struct {
 	data  *sessionData
 	mutex sync.RWMutex // 零值即可用,不用初始化
+
+	// removedCounters holds the keys of counters removed by Delete or Clear since the session was last saved,
+	// so stores keeping counters apart from the values, i.e. RedisStore, remove them as well.
+	removedCounters map[string]struct{}
+	// committed holds the values last accepted by a MemoryStore with limits,
+	// which restores them when a save exceeds the limits, see commit and rollback.
+	committed map[string]interface{}
 }
 
 // sessionData 内部的数据结构, 用于序列化
@@ -19,17 +27,31 @@ type sessionData struct {
 	Expiry  int64                  `json:"expiry"`
 	Values  map[string]interface{} `json:"values"`  // sync.Map 对 redis 存储支持不友好, 序列化/反序列化需要额外的转换步骤
 	Options *Options               `json:"options"` // cookie 相关配置
+	UserID  string                 `json:"user_id,omitempty"`
+	Version int                    `json:"version,omitempty"` // schema version of Values, see RegisterUpgrade
+
+	// Metadata captured by the store, read-only for users.
+	CreatedAt      int64  `json:"created_at"`
+	LastAccessedAt int64  `json:"last_accessed_at"`
+	IP             string `json:"ip,omitempty"`
+	UserAgentHash  string `json:"user_agent_hash,omitempty"`
+	Fingerprint    string `json:"fingerprint,omitempty"` // client fingerprint the session is bound to, see FingerprintPolicy
+	CSRFSecret     []byte `json:"csrf_secret,omitempty"`
 }
 
 func NewSession(name, id string, options Options) *Session {
+	now := time.Now()
 	return &Session{
 		data: &sessionData{
-			Name:    name,
-			ID:      id,
-			IsNew:   true,
-			Expiry:  time.Now().Add(time.Duration(options.MaxAge) * time.Second).Unix(),
-			Values:  make(map[string]interface{}),
-			Options: &options,
+			Name:           name,
+			ID:             id,
+			IsNew:          true,
+			Expiry:         now.Add(time.Duration(options.MaxAge) * time.Second).Unix(),
+			Values:         make(map[string]interface{}),
+			Options:        &options,
+			Version:        SchemaVersion(),
+			CreatedAt:      now.Unix(),
+			LastAccessedAt: now.Unix(),
 		},
 	}
 }
@@ -55,6 +77,42 @@ func (s *Session) GetName() string {
 	return s.data.Name
 }
 
+// GetUserID returns the ID of the user the session is bound to,
+// or an empty string if it's not bound to any user.
+func (s *Session) GetUserID() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.data.UserID
+}
+
+// GetCreatedAt returns the time when the session was created.
+func (s *Session) GetCreatedAt() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return time.Unix(s.data.CreatedAt, 0)
+}
+
+// GetLastAccessedAt returns the time when the session was last fetched by Store.Get.
+func (s *Session) GetLastAccessedAt() time.Time {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return time.Unix(s.data.LastAccessedAt, 0)
+}
+
+// GetIP returns the client IP address of the last request which fetched the session.
+func (s *Session) GetIP() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.data.IP
+}
+
+// GetUserAgentHash returns the SHA-256 of the User-Agent of the last request which fetched the session.
+func (s *Session) GetUserAgentHash() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.data.UserAgentHash
+}
+
 func (s *Session) IsNew() bool {
 	s.mutex.RLock()
 	defer s.mutex.RUnlock()
@@ -83,6 +141,151 @@ func (s *Session) SetValue(k string, v interface{}) {
 	s.data.Values[k] = v
 }
 
+// Delete removes the value whose key is k, it does nothing if there is no such value.
+// A counter incremented with Counter.Incr is removed from the store when the session is saved.
+func (s *Session) Delete(k string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.removeCounter(k)
+	delete(s.data.Values, k)
+}
+
+// Has reports whether there is a value whose key is k, even if the value is nil.
+func (s *Session) Has(k string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, ok := s.data.Values[k]
+	return ok
+}
+
+// Keys returns the keys of all values, sorted.
+func (s *Session) Keys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := make([]string, 0, len(s.data.Values))
+	for k := range s.data.Values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Len returns the number of values.
+func (s *Session) Len() int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return len(s.data.Values)
+}
+
+// Incr adds delta to the integer value whose key is k and returns the new value,
+// a missing or non-numeric value counts as 0. The value is stored as an int64.
+// It's atomic for this Session, use Counter.Incr of the store when requests may load the session concurrently.
+func (s *Session) Incr(k string, delta int64) int64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	n := toInt64(s.data.Values[k]) + delta
+	s.data.Values[k] = n
+	return n
+}
+
+// Clear removes all values, the metadata of the session is kept.
+// Counters incremented with Counter.Incr are removed from the store when the session is saved.
+func (s *Session) Clear() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for k := range s.data.Values {
+		s.removeCounter(k)
+	}
+	s.data.Values = make(map[string]interface{})
+}
+
+// removeCounter records that the value whose key is k is removed if it may be a counter,
+// counters are int64, while numbers decoded from JSON are float64. The caller must hold s.mutex.
+func (s *Session) removeCounter(k string) {
+	if _, ok := s.data.Values[k].(int64); !ok {
+		return
+	}
+	if s.removedCounters == nil {
+		s.removedCounters = make(map[string]struct{})
+	}
+	s.removedCounters[k] = struct{}{}
+}
+
+// isCounterRemoved reports whether the counter whose key is k was removed since the session was last saved.
+func (s *Session) isCounterRemoved(k string) bool {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	_, ok := s.removedCounters[k]
+	return ok
+}
+
+// setCounter sets the counter whose key is k to n, which has been stored, so it's no longer removed.
+func (s *Session) setCounter(k string, n int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.removedCounters, k)
+	s.data.Values[k] = n
+}
+
+// removedCounterKeys returns the keys of the counters removed since the session was last saved.
+func (s *Session) removedCounterKeys() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	keys := make([]string, 0, len(s.removedCounters))
+	for k := range s.removedCounters {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// forgetRemovedCounters drops the keys of removed counters once they have been removed from the store.
+func (s *Session) forgetRemovedCounters(keys []string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, k := range keys {
+		delete(s.removedCounters, k)
+	}
+}
+
+// commit records the values of the session as the ones accepted by the store, they are copied shallowly.
+func (s *Session) commit() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.committed = make(map[string]interface{}, len(s.data.Values))
+	for k, v := range s.data.Values {
+		s.committed[k] = v
+	}
+}
+
+// commitValue records v as the accepted value of k, for values the store writes directly, i.e. counters.
+func (s *Session) commitValue(k string, v interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.committed != nil {
+		s.committed[k] = v
+	}
+}
+
+// rollback restores the values recorded by commit, or no values if nothing was committed.
+func (s *Session) rollback() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.data.Values = make(map[string]interface{}, len(s.committed))
+	for k, v := range s.committed {
+		s.data.Values[k] = v
+	}
+}
+
+// Update replaces the value whose key is k with fn(old) and returns it, old is nil if there is no such value.
+// The session is locked while fn runs, so concurrent updates aren't lost, but fn must not use the session.
+func (s *Session) Update(k string, fn func(old interface{}) interface{}) interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	v := fn(s.data.Values[k])
+	s.data.Values[k] = v
+	return v
+}
+
 // GetOptions Return a copy of Options of a Session value.
 // In case of data race.
 func (s *Session) GetOptions() Options {
@@ -0,0 +1,47 @@
+package sessions
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession_Values(t *testing.T) {
+	session := NewSession("session-key", "id", *defaultOptions())
+	session.SetValue("name", "Coco")
+	session.SetValue("nothing", nil)
+
+	assert.True(t, session.Has("nothing"), "nil values exist")
+	assert.False(t, session.Has("missing"))
+	assert.Equal(t, []string{"name", "nothing"}, session.Keys())
+	assert.Equal(t, 2, session.Len())
+
+	session.Delete("nothing")
+	session.Delete("missing")
+	assert.Equal(t, []string{"name"}, session.Keys())
+
+	session.Clear()
+	assert.Equal(t, 0, session.Len())
+	assert.Equal(t, "id", session.GetID(), "metadata is kept")
+	session.SetValue("name", "Coco")
+	assert.True(t, session.Has("name"))
+}
+
+func TestSession_Update(t *testing.T) {
+	session := NewSession("session-key", "id", *defaultOptions())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session.Update("count", func(old interface{}) interface{} {
+				count, _ := old.(int)
+				return count + 1
+			})
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, 100, session.GetValueByKey("count"))
+}
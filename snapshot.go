@@ -0,0 +1,135 @@
+package sessions
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maxSnapshotFrame guards Restore against allocating huge buffers for corrupt input.
+const maxSnapshotFrame = 64 << 20
+
+// Snapshot writes all non-expired sessions to w, so they can be restored with Restore,
+// e.g. after a restart. Each session is serialized with the snapshot codec, see WithSnapshotCodec,
+// and written as a frame prefixed with its length as a uvarint.
+func (s *MemoryStore) Snapshot(w io.Writer) error {
+	serializer := s.snapshotCodec
+	bw := bufio.NewWriter(w)
+	now := time.Now().Unix()
+
+	// w may be slow, so sessions are written without holding the lock of the store.
+	s.mutex.RLock()
+	sessions := make([]*Session, 0, len(s.sessions))
+	for _, session := range s.sessions {
+		if session.data.Expiry > now {
+			sessions = append(sessions, session)
+		}
+	}
+	s.mutex.RUnlock()
+
+	for _, session := range sessions {
+		data, err := serializer.Serialize(session)
+		if err != nil {
+			return err
+		}
+		if _, err := bw.Write(binary.AppendUvarint(nil, uint64(len(data)))); err != nil {
+			return err
+		}
+		if _, err := bw.Write(data); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// Restore reads sessions written by Snapshot from r into the store.
+// Sessions that have expired in the meantime are skipped,
+// sessions with the same ID as an existing session replace it.
+func (s *MemoryStore) Restore(r io.Reader) error {
+	serializer := s.snapshotCodec
+	br := bufio.NewReader(r)
+	now := time.Now().Unix()
+
+	var restored []*Session
+	for {
+		size, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+		if size > maxSnapshotFrame {
+			return fmt.Errorf("failed to read snapshot: frame of %d bytes is too large", size)
+		}
+		data := make([]byte, size)
+		if _, err := io.ReadFull(br, data); err != nil {
+			return fmt.Errorf("failed to read snapshot: %w", err)
+		}
+
+		session, err := decodeSession(serializer, data)
+		if err != nil {
+			return err
+		}
+		if session.data.Expiry <= now {
+			continue
+		}
+		session.data.IsNew = false
+		if s.limits != nil {
+			session.commit()
+		}
+		restored = append(restored, session)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, session := range restored {
+		if old, ok := s.sessions[session.data.ID]; ok {
+			s.unbindUser(old.GetUserID(), session.data.ID)
+		}
+		s.sessions[session.data.ID] = session
+		if userID := session.data.UserID; userID != "" {
+			if _, ok := s.users[userID]; !ok {
+				s.users[userID] = make(map[string]struct{})
+			}
+			s.users[userID][session.data.ID] = struct{}{}
+		}
+	}
+	return nil
+}
+
+// loadSnapshotFile restores the sessions in the snapshot file, a missing file isn't an error.
+func (s *MemoryStore) loadSnapshotFile() error {
+	f, err := os.Open(s.snapshotPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	return s.Restore(f)
+}
+
+// writeSnapshotFile atomically replaces the snapshot file with the current sessions.
+func (s *MemoryStore) writeSnapshotFile() error {
+	var buf bytes.Buffer
+	if err := s.Snapshot(&buf); err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Dir(s.snapshotPath), s.snapshotPath, buf.Bytes())
+}
+
+// snapshotPeriodically writes the snapshot file every snapshotInterval.
+func (s *MemoryStore) snapshotPeriodically() {
+	ticker := time.NewTicker(s.snapshotInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		_ = s.writeSnapshotFile()
+	}
+}
@@ -0,0 +1,113 @@
+package sessions
+
+import (
+	"bytes"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore_SnapshotRestore(t *testing.T) {
+	store, _ := NewMemoryStore()
+	memoryStore := store.(*MemoryStore)
+
+	live, _ := store.New("session-key")
+	live.SetValue("name", "Coco")
+	_ = memoryStore.BindUser(live, "coco")
+	expired, _ := store.New("session-key")
+	// Expire the session without waiting for gc to remove it.
+	expired.data.Expiry = time.Now().Unix() - 1
+
+	var buf bytes.Buffer
+	assert.NoError(t, memoryStore.Snapshot(&buf))
+
+	restarted, _ := NewMemoryStore()
+	assert.NoError(t, restarted.(*MemoryStore).Restore(&buf))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: live.GetID()})
+	session, err := restarted.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.False(t, session.IsNew())
+	assert.Equal(t, "Coco", session.GetValueByKey("name"))
+	assert.Equal(t, live.data.Expiry, session.data.Expiry)
+
+	sessions, _ := restarted.(*MemoryStore).ListByUser("coco")
+	assert.Len(t, sessions, 1)
+	assert.Len(t, restarted.(*MemoryStore).sessions, 1, "expired session shouldn't be restored")
+
+	assert.Error(t, restarted.(*MemoryStore).Restore(bytes.NewReader([]byte{0x05, '{'})))
+}
+
+func TestMemoryStore_SnapshotFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions.snapshot")
+	store, err := NewMemoryStore(WithSnapshotFile(path, time.Hour))
+	assert.NoError(t, err)
+	session, _ := store.New("session-key")
+	assert.NoError(t, store.(*MemoryStore).writeSnapshotFile())
+
+	restarted, err := NewMemoryStore(WithSnapshotFile(path, time.Hour))
+	assert.NoError(t, err)
+	restarted.(*MemoryStore).mutex.RLock()
+	_, ok := restarted.(*MemoryStore).sessions[session.GetID()]
+	restarted.(*MemoryStore).mutex.RUnlock()
+	assert.True(t, ok)
+}
+
+func TestMemoryStore_SnapshotCodec(t *testing.T) {
+	codec, _ := Encrypt(nil, "key", map[string][]byte{"key": bytes.Repeat([]byte{1}, 32)})
+	path := filepath.Join(t.TempDir(), "sessions.snapshot")
+	store, _ := NewMemoryStore(WithSnapshotFile(path, time.Hour), WithSnapshotCodec(codec))
+	session, _ := store.New("session-key")
+	session.SetValue("email", "coco@example.com")
+	assert.NoError(t, store.(*MemoryStore).writeSnapshotFile())
+
+	data, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(data), "coco@example.com")
+
+	restarted, err := NewMemoryStore(WithSnapshotFile(path, time.Hour), WithSnapshotCodec(codec))
+	assert.NoError(t, err)
+	restored, err := restarted.(Inspector).Load(session.GetID())
+	assert.NoError(t, err)
+	assert.Equal(t, "coco@example.com", restored.GetValueByKey("email"))
+}
+
+// blockingWriter blocks writes until it's released.
+type blockingWriter struct {
+	release chan struct{}
+}
+
+func (w *blockingWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestMemoryStore_SnapshotDoesNotBlockStore(t *testing.T) {
+	store, _ := NewMemoryStore()
+	session, _ := store.New("session-key")
+	session.SetValue("notes", strings.Repeat("a", 8192))
+
+	w := &blockingWriter{release: make(chan struct{})}
+	done := make(chan error)
+	go func() { done <- store.(*MemoryStore).Snapshot(w) }()
+
+	// The store is usable while the snapshot waits on a slow writer.
+	created := make(chan struct{})
+	go func() {
+		_, _ = store.New("session-key")
+		close(created)
+	}()
+	select {
+	case <-created:
+	case <-time.After(time.Second):
+		t.Fatal("New blocked by Snapshot")
+	}
+	close(w.release)
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,403 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Dialect is the SQL dialect of the database behind a SQLStore.
+type Dialect int
+
+const (
+	DialectSQLite Dialect = iota
+	DialectPostgres
+	DialectMySQL
+)
+
+// tableNamePattern restricts table names, they are interpolated into queries.
+var tableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// SQLStore represents a session store backed by a relational database through database/sql.
+// Each session is a row holding its serialized data and expiry time,
+// expired rows are deleted periodically through an index on the expiry column.
+type SQLStore struct {
+	*baseStore
+	db         *sql.DB
+	dialect    Dialect
+	table      string
+	serializer Codec
+	gcInterval time.Duration
+}
+
+// NewSQLStore creates a SQLStore on db and creates the sessions table if it doesn't exist, see Migrate.
+func NewSQLStore(db *sql.DB, dialect Dialect, options ...func(store *SQLStore)) (Store, error) {
+	base, err := newBaseStore(defaultOptions(), 16)
+	if err != nil {
+		return nil, err
+	}
+	if dialect != DialectSQLite && dialect != DialectPostgres && dialect != DialectMySQL {
+		return nil, fmt.Errorf("unsupported sql dialect: %d", dialect)
+	}
+
+	store := &SQLStore{
+		baseStore:  base,
+		db:         db,
+		dialect:    dialect,
+		table:      "sessions",
+		serializer: &Serializer{},
+		gcInterval: time.Minute,
+	}
+
+	for _, op := range options {
+		op(store)
+	}
+
+	if err := store.Migrate(context.Background()); err != nil {
+		return nil, err
+	}
+
+	go store.gc()
+
+	return store, nil
+}
+
+// Option functions for customizing SQLStore
+
+// WithSQLOptions sets the cookie options for the store
+func WithSQLOptions(options *Options) func(store *SQLStore) {
+	return func(store *SQLStore) {
+		if options == nil {
+			return
+		}
+		if err := options.Validate(); err != nil {
+			panic(err)
+		}
+		store.options = options
+	}
+}
+
+// WithSQLSessionIDLength sets the session ID length
+func WithSQLSessionIDLength(length int) func(store *SQLStore) {
+	return func(store *SQLStore) {
+		if length <= 0 {
+			panic("session ID length must be greater than 0")
+		}
+		store.idLength = length
+	}
+}
+
+// WithSQLTable sets the name of the sessions table, "sessions" by default
+func WithSQLTable(table string) func(store *SQLStore) {
+	return func(store *SQLStore) {
+		if !tableNamePattern.MatchString(table) {
+			panic("invalid table name: " + table)
+		}
+		store.table = table
+	}
+}
+
+// WithSQLGCInterval sets the interval of deleting expired sessions
+func WithSQLGCInterval(interval time.Duration) func(store *SQLStore) {
+	return func(store *SQLStore) {
+		if interval <= 0 {
+			panic("GC interval must be greater than 0")
+		}
+		store.gcInterval = interval
+	}
+}
+
+// WithSQLCodec sets the codec of the data column, the JSON Serializer by default.
+func WithSQLCodec(codec Codec) func(store *SQLStore) {
+	return func(store *SQLStore) {
+		if codec == nil {
+			panic("codec cannot be nil")
+		}
+		store.serializer = codec
+	}
+}
+
+// WithSQLLimits limits the size of sessions, see WithLimits. MaxSize applies to the data column.
+func WithSQLLimits(limits Limits) func(store *SQLStore) {
+	return func(store *SQLStore) {
+		store.limits = validateLimits(limits)
+	}
+}
+
+// WithSQLTrustedProxies sets the proxies whose X-Forwarded-For header is trusted
+// when capturing the client IP of a session.
+func WithSQLTrustedProxies(proxies ...string) func(store *SQLStore) {
+	return func(store *SQLStore) {
+		networks, err := parseCIDRs(proxies)
+		if err != nil {
+			panic(err)
+		}
+		store.trustedProxies = networks
+	}
+}
+
+// WithSQLFingerprint binds every new session to a fingerprint of its client,
+// see FingerprintPolicy.
+func WithSQLFingerprint(policy FingerprintPolicy) func(store *SQLStore) {
+	return func(store *SQLStore) {
+		store.fingerprint = &policy
+	}
+}
+
+// WithSQLLogger sets the logger of the store, see WithLogger.
+func WithSQLLogger(logger *slog.Logger) func(store *SQLStore) {
+	return func(store *SQLStore) {
+		store.setLogger(logger)
+	}
+}
+
+// Schema returns the statements creating the sessions table and its expiry index.
+// The statements are idempotent, so they can be run on every start.
+func (s *SQLStore) Schema() []string {
+	switch s.dialect {
+	case DialectMySQL:
+		// IDs are compared as bytes, the default collations of MySQL ignore case.
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+	id VARBINARY(255) NOT NULL PRIMARY KEY,
+	data LONGBLOB NOT NULL,
+	expiry BIGINT NOT NULL,
+	INDEX ` + s.table + `_expiry_idx (expiry)
+)`,
+		}
+	case DialectPostgres:
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+	id VARCHAR(255) NOT NULL PRIMARY KEY,
+	data BYTEA NOT NULL,
+	expiry BIGINT NOT NULL
+)`,
+			`CREATE INDEX IF NOT EXISTS ` + s.table + `_expiry_idx ON ` + s.table + ` (expiry)`,
+		}
+	default:
+		return []string{
+			`CREATE TABLE IF NOT EXISTS ` + s.table + ` (
+	id VARCHAR(255) NOT NULL PRIMARY KEY,
+	data BLOB NOT NULL,
+	expiry INTEGER NOT NULL
+)`,
+			`CREATE INDEX IF NOT EXISTS ` + s.table + `_expiry_idx ON ` + s.table + ` (expiry)`,
+		}
+	}
+}
+
+// Migrate creates the sessions table and its expiry index if they don't exist.
+func (s *SQLStore) Migrate(ctx context.Context) error {
+	for _, stmt := range s.Schema() {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("failed to migrate sessions table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Get retrieves a session by name from the database or creates a new one.
+func (s *SQLStore) Get(r *http.Request, name string) (*Session, error) {
+	return s.getSession(s, r, name)
+}
+
+// New creates a new session and saves it into the database.
+func (s *SQLStore) New(name string) (*Session, error) {
+	return s.createSession(s, nil, name)
+}
+
+// Save inserts or updates the row of the session.
+func (s *SQLStore) Save(session *Session) error {
+	data, err := s.serializer.Serialize(session)
+	if err != nil {
+		return err
+	}
+	s.observeSize(len(data))
+	if err := s.checkLimits(session, data); err != nil {
+		return err
+	}
+	session.mutex.RLock()
+	id, expiry := session.data.ID, session.data.Expiry
+	session.mutex.RUnlock()
+
+	if _, err = s.db.ExecContext(context.Background(), s.upsertQuery(), id, data, expiry); err != nil {
+		return unavailable(err)
+	}
+	s.emit(EventSaved, session)
+	return nil
+}
+
+// Delete removes the row of the session.
+func (s *SQLStore) Delete(session *Session) error {
+	result, err := s.db.ExecContext(context.Background(),
+		s.rebind(`DELETE FROM `+s.table+` WHERE id = ?`), session.GetID())
+	if err != nil {
+		return unavailable(err)
+	}
+	// The row may have been deleted by gc already.
+	if deleted, err := result.RowsAffected(); err == nil && deleted > 0 {
+		s.emit(EventDestroyed, session)
+	}
+	return nil
+}
+
+// load reads the session with the id, it returns nil if the session doesn't exist or has expired.
+func (s *SQLStore) load(id string) (*Session, error) {
+	var data []byte
+	err := s.db.QueryRowContext(context.Background(),
+		s.rebind(`SELECT data FROM `+s.table+` WHERE id = ? AND expiry > ?`), id, time.Now().Unix()).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	} else if err != nil {
+		s.logLoadError(id, err)
+		return nil, unavailable(err)
+	}
+
+	session, err := decodeSession(s.serializer, data)
+	if err != nil {
+		s.logDecodeError(id, err)
+		return nil, err
+	}
+	return session, nil
+}
+
+// Range calls fn for every live session in the table until fn returns false.
+// Rows are streamed while fn runs, so fn shouldn't use the store when db allows a single connection.
+func (s *SQLStore) Range(ctx context.Context, fn func(session *Session) bool) error {
+	rows, err := s.db.QueryContext(ctx,
+		s.rebind(`SELECT data FROM `+s.table+` WHERE expiry > ?`), time.Now().Unix())
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		session, err := decodeSession(s.serializer, data)
+		if err != nil {
+			return err
+		}
+		session.data.IsNew = false
+		if !fn(session) {
+			return nil
+		}
+	}
+	return rows.Err()
+}
+
+// generateID generates a unique session ID.
+func (s *SQLStore) generateID() (string, error) {
+	for {
+		id, err := generateRandomID(s.idLength)
+		if err != nil {
+			return "", err
+		}
+		var exists int
+		err = s.db.QueryRowContext(context.Background(),
+			s.rebind(`SELECT 1 FROM `+s.table+` WHERE id = ?`), id).Scan(&exists)
+		if errors.Is(err, sql.ErrNoRows) {
+			return id, nil
+		} else if err != nil {
+			return "", err
+		}
+	}
+}
+
+// upsertQuery returns the dialect specific statement inserting or updating a session row.
+func (s *SQLStore) upsertQuery() string {
+	if s.dialect == DialectMySQL {
+		return `INSERT INTO ` + s.table + ` (id, data, expiry) VALUES (?, ?, ?)
+ON DUPLICATE KEY UPDATE data = VALUES(data), expiry = VALUES(expiry)`
+	}
+	return s.rebind(`INSERT INTO ` + s.table + ` (id, data, expiry) VALUES (?, ?, ?)
+ON CONFLICT (id) DO UPDATE SET data = excluded.data, expiry = excluded.expiry`)
+}
+
+// rebind replaces the ? placeholders of query with $1, $2... for Postgres.
+func (s *SQLStore) rebind(query string) string {
+	if s.dialect != DialectPostgres {
+		return query
+	}
+	var b strings.Builder
+	n := 0
+	for _, c := range query {
+		if c == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(c)
+	}
+	return b.String()
+}
+
+// gc periodically deletes expired sessions
+func (s *SQLStore) gc() {
+	ticker := time.NewTicker(s.gcInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if _, err := s.sweep(); err != nil {
+			s.logSweepError(err)
+		}
+	}
+}
+
+// sweep deletes the rows of expired sessions and returns how many were deleted.
+// The IDs of expired rows are read first, not every dialect supports DELETE ... RETURNING,
+// so EventExpired can be emitted for every deleted row.
+func (s *SQLStore) sweep() (int64, error) {
+	start := time.Now()
+	ctx := context.Background()
+	now := time.Now().Unix()
+	ids, err := s.expiredIDs(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	var expired []string
+	for _, id := range ids {
+		// The session may have been saved with a new expiry since its ID was read.
+		var result sql.Result
+		result, err = s.db.ExecContext(ctx,
+			s.rebind(`DELETE FROM `+s.table+` WHERE id = ? AND expiry <= ?`), id, now)
+		if err != nil {
+			break
+		}
+		if deleted, err := result.RowsAffected(); err == nil && deleted > 0 {
+			expired = append(expired, id)
+		}
+	}
+	s.observeSweep(start, len(expired))
+	for _, id := range expired {
+		s.emitID(EventExpired, id, "")
+	}
+	return int64(len(expired)), err
+}
+
+// expiredIDs returns the IDs of the sessions which expired at now.
+func (s *SQLStore) expiredIDs(ctx context.Context, now int64) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT id FROM `+s.table+` WHERE expiry <= ?`), now)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
@@ -0,0 +1,106 @@
+package sessions
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	_ "modernc.org/sqlite"
+)
+
+func setupSQLStore(t *testing.T) (*sql.DB, *SQLStore) {
+	db, err := sql.Open("sqlite", ":memory:")
+	assert.NoError(t, err)
+	// Every connection to ":memory:" opens its own database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { _ = db.Close() })
+
+	store, err := NewSQLStore(db, DialectSQLite)
+	assert.NoError(t, err)
+	return db, store.(*SQLStore)
+}
+
+func TestSQLStore_GetSaveDelete(t *testing.T) {
+	_, store := setupSQLStore(t)
+	// Migrations are idempotent.
+	assert.NoError(t, store.Migrate(context.Background()))
+
+	session, err := store.New("session-key")
+	assert.NoError(t, err)
+	session.SetValue("name", "Coco")
+	assert.NoError(t, store.Save(session))
+	// Saving again updates the row instead of inserting a new one.
+	session.SetValue("name", "Bella")
+	assert.NoError(t, store.Save(session))
+
+	req, _ := http.NewRequest("GET", "http://localhost:8080/", nil)
+	req.AddCookie(&http.Cookie{Name: "session-key", Value: session.GetID()})
+	loaded, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.False(t, loaded.IsNew())
+	assert.Equal(t, "Bella", loaded.GetValueByKey("name"))
+
+	assert.NoError(t, store.Delete(loaded))
+	loaded, err = store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.True(t, loaded.IsNew())
+}
+
+func TestSQLStore_Sweep(t *testing.T) {
+	db, store := setupSQLStore(t)
+	recorder := &eventRecorder{}
+	store.AddListener(recorder.listen)
+
+	expired, _ := store.New("session-key")
+	expired.SetMaxAge(-1)
+	assert.NoError(t, store.Save(expired))
+	_, _ = store.New("session-key")
+
+	loaded, err := store.load(expired.GetID())
+	assert.NoError(t, err)
+	assert.Nil(t, loaded, "expired sessions shouldn't be loaded before the sweep")
+
+	deleted, err := store.sweep()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Equal(t, 1, recorder.count(EventExpired))
+	var count int
+	assert.NoError(t, db.QueryRow(`SELECT COUNT(*) FROM sessions`).Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestSQLStore_Dialects(t *testing.T) {
+	postgres := &SQLStore{dialect: DialectPostgres, table: "sessions"}
+	assert.Equal(t, "SELECT data FROM sessions WHERE id = $1 AND expiry > $2",
+		postgres.rebind("SELECT data FROM sessions WHERE id = ? AND expiry > ?"))
+	assert.Contains(t, postgres.upsertQuery(), "VALUES ($1, $2, $3)")
+	assert.Contains(t, postgres.Schema()[0], "BYTEA")
+
+	mysql := &SQLStore{dialect: DialectMySQL, table: "sessions"}
+	assert.Contains(t, mysql.upsertQuery(), "ON DUPLICATE KEY UPDATE")
+	assert.Len(t, mysql.Schema(), 1)
+	// Session IDs are case sensitive.
+	assert.Contains(t, mysql.Schema()[0], "id VARBINARY(255) NOT NULL PRIMARY KEY")
+}
+
+func TestSQLStore_Fingerprint(t *testing.T) {
+	db, _ := setupSQLStore(t)
+	store, err := NewSQLStore(db, DialectSQLite,
+		WithSQLTrustedProxies("10.0.0.0/8"),
+		WithSQLFingerprint(FingerprintPolicy{IPv4Prefix: 24, Invalidate: true}))
+	assert.NoError(t, err)
+
+	req := newFingerprintRequest(nil, "10.0.0.1:1234", "firefox")
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+	session, err := store.Get(req, "session-key")
+	assert.NoError(t, err)
+	assert.Equal(t, "203.0.113.7", session.GetIP())
+	cookie := &http.Cookie{Name: "session-key", Value: session.GetID()}
+
+	stolen, err := store.Get(newFingerprintRequest(cookie, "198.51.100.1:1234", "firefox"), "session-key")
+	assert.NoError(t, err)
+	assert.True(t, stolen.IsNew())
+	assert.NotEqual(t, session.GetID(), stolen.GetID())
+}
@@ -1,9 +1,14 @@
 package sessions
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
+	"sync"
+	"sync/atomic"
 )
 
 // Store interface defines the contract for session storage implementations
@@ -25,10 +30,67 @@ type Store interface {
 	Delete(session *Session) error
 }
 
+// UserIndex is implemented by stores that can bind sessions to a user,
+// e.g. for "log out everywhere" or listing the active devices of a user.
+type UserIndex interface {
+	// BindUser binds the session to the user and persists the session.
+	// A session is bound to one user at most, binding it again replaces the old user.
+	BindUser(session *Session, userID string) error
+
+	// ListByUser returns all live sessions bound to the user
+	ListByUser(userID string) ([]*Session, error)
+
+	// RevokeUser deletes all sessions bound to the user
+	RevokeUser(userID string) error
+}
+
+// Scanner is implemented by stores that can iterate over all their sessions.
+type Scanner interface {
+	// Range calls fn for every live session in the store until fn returns false.
+	// The order of sessions is unspecified.
+	Range(ctx context.Context, fn func(session *Session) bool) error
+}
+
+// Inspector is implemented by stores that can be inspected by administrators,
+// e.g. to count sessions or list them on a dashboard.
+type Inspector interface {
+	// Count returns the number of sessions in the store
+	Count() (int, error)
+
+	// List returns up to about limit sessions after the cursor, and the cursor of the next page.
+	// An empty cursor starts from the beginning, an empty next cursor means there are no more sessions.
+	List(cursor string, limit int) (sessions []*Session, next string, err error)
+
+	// Load returns the session with the id, or ErrNotFound
+	Load(id string) (*Session, error)
+
+	// DeleteByID deletes the session with the id, or returns ErrNotFound
+	DeleteByID(id string) error
+}
+
+// Counter is implemented by stores that can increment session values atomically,
+// so the increments of concurrent requests sharing a session aren't lost
+// the way read-modify-write of whole sessions with Save loses them.
+type Counter interface {
+	// Incr adds delta to the integer value of key in the session, persists it, and returns the new value.
+	// The value of the session is updated as well. Keys incremented with Incr should only be changed with Incr,
+	// or removed with Session.Delete or Clear.
+	Incr(session *Session, key string, delta int64) (int64, error)
+}
+
 // baseStore implements common functionality for all stores
 type baseStore struct {
-	options  *Options // default cookie options value when creating a new session
-	idLength int      // length of the session ID
+	options        *Options           // default cookie options value when creating a new session
+	idLength       int                // length of the session ID
+	trustedProxies []*net.IPNet       // proxies whose X-Forwarded-For header is trusted
+	fingerprint    *FingerprintPolicy // nil if sessions aren't bound to clients
+
+	listenersMutex sync.RWMutex
+	listeners      []Listener
+
+	metrics atomic.Pointer[Metrics] // set by Instrument, nil if the store isn't instrumented
+	logger  *slog.Logger
+	limits  *Limits // nil if sessions aren't limited
 }
 
 // NewBaseStore creates a new baseStore with default options
@@ -48,5 +110,70 @@ func newBaseStore(opts *Options, idLen int) (*baseStore, error) {
 	return &baseStore{
 		options:  opts,
 		idLength: idLen,
+		logger:   discardLogger,
 	}, nil
 }
+
+// backend is implemented by the stores which serialize sessions into a backend,
+// their Get and New are implemented by getSession and createSession.
+type backend interface {
+	// load returns the live session with the id, or nil if there is none.
+	load(id string) (*Session, error)
+	generateID() (string, error)
+	Save(session *Session) error
+	Delete(session *Session) error
+}
+
+// getSession returns the session of the request cookie from the backend,
+// if there is no such session or it has expired, a new session is created.
+func (b *baseStore) getSession(s backend, r *http.Request, name string) (*Session, error) {
+	if !isCookieNameValid(name) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCookieName, name)
+	}
+
+	// IDs of another length can't have been generated by the store,
+	// and long ones would make file names too long.
+	cookie, err := r.Cookie(name)
+	if err != nil || len(cookie.Value) != b.idLength || !isSessionIDValid(cookie.Value) {
+		return b.createSession(s, r, name)
+	}
+
+	session, err := s.load(cookie.Value)
+	if err != nil {
+		return nil, err
+	}
+	if session == nil {
+		b.emitID(EventMissed, cookie.Value, name)
+		return b.createSession(s, r, name)
+	}
+	if !b.verifyFingerprint(session, r) {
+		if !b.fingerprint.Invalidate {
+			return nil, ErrSessionHijackSuspected
+		}
+		if err := s.Delete(session); err != nil {
+			return nil, err
+		}
+		return b.createSession(s, r, name)
+	}
+	session.data.IsNew = false
+	b.touch(session, r)
+	b.emit(EventLoaded, session)
+	return session, nil
+}
+
+// createSession creates a new session for the request r and saves it into the backend,
+// r may be nil if the session isn't created for a request.
+func (b *baseStore) createSession(s backend, r *http.Request, name string) (*Session, error) {
+	id, err := s.generateID()
+	if err != nil {
+		return nil, err
+	}
+
+	session := NewSession(name, id, *b.options)
+	b.touch(session, r)
+	if err := s.Save(session); err != nil {
+		return nil, err
+	}
+	b.emit(EventCreated, session)
+	return session, nil
+}
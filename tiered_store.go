@@ -0,0 +1,335 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TieredStore serves sessions from a local MemoryStore (L1) in front of a RedisStore,
+// which is the source of truth.
+//
+// Reads hit the L1 as long as the cached session is younger than the cache TTL,
+// writes go through to Redis, and every write is published on a Redis channel
+// so other instances drop their stale L1 copy.
+type TieredStore struct {
+	local    *MemoryStore
+	remote   *RedisStore
+	ttl      time.Duration
+	channel  string
+	instance string // ID of this instance, invalidations sent by itself are ignored
+
+	mutex    sync.Mutex
+	loadedAt map[string]time.Time // session ID -> time the session was cached
+}
+
+// NewTieredStore creates a TieredStore in front of the given RedisStore.
+// It subscribes to the invalidation channel before returning.
+func NewTieredStore(remote *RedisStore, options ...func(store *TieredStore)) (Store, error) {
+	local, err := NewMemoryStore()
+	if err != nil {
+		return nil, err
+	}
+	instance, err := generateRandomID(16)
+	if err != nil {
+		return nil, err
+	}
+
+	store := &TieredStore{
+		local:    local.(*MemoryStore),
+		remote:   remote,
+		ttl:      5 * time.Second,
+		channel:  "sessions:invalidate",
+		instance: instance,
+		loadedAt: make(map[string]time.Time),
+	}
+
+	for _, op := range options {
+		op(store)
+	}
+
+	pubsub := remote.client.Subscribe(context.Background(), store.channel)
+	if _, err := pubsub.Receive(context.Background()); err != nil {
+		_ = pubsub.Close()
+		return nil, fmt.Errorf("failed to subscribe to invalidation channel: %w", redisError(err))
+	}
+	go store.listen(pubsub.Channel())
+	go store.evict()
+
+	return store, nil
+}
+
+// WithTieredTTL sets how long a session is served from the local cache
+// before it's read from Redis again.
+func WithTieredTTL(ttl time.Duration) func(store *TieredStore) {
+	return func(store *TieredStore) {
+		if ttl <= 0 {
+			panic("cache TTL must be greater than 0")
+		}
+		store.ttl = ttl
+	}
+}
+
+// WithTieredChannel sets the Redis channel used to invalidate local caches,
+// all instances sharing the same sessions must use the same channel.
+func WithTieredChannel(channel string) func(store *TieredStore) {
+	return func(store *TieredStore) {
+		if channel == "" {
+			panic("invalidation channel cannot be empty")
+		}
+		store.channel = channel
+	}
+}
+
+// Get returns the session from the local cache if it's fresh enough,
+// otherwise it reads the session from Redis and caches it.
+func (s *TieredStore) Get(r *http.Request, name string) (*Session, error) {
+	if !isCookieNameValid(name) {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCookieName, name)
+	}
+	if c, err := r.Cookie(name); err == nil {
+		if session, ok := s.cached(c.Value); ok {
+			return s.fromCache(r, name, session)
+		}
+	}
+
+	session, err := s.remote.Get(r, name)
+	if err != nil {
+		return nil, err
+	}
+	s.cache(session)
+	return session, nil
+}
+
+// fromCache applies the fingerprint policy of the RedisStore to a cached session.
+func (s *TieredStore) fromCache(r *http.Request, name string, session *Session) (*Session, error) {
+	if !s.remote.verifyFingerprint(session, r) {
+		if !s.remote.fingerprint.Invalidate {
+			return nil, ErrSessionHijackSuspected
+		}
+		if err := s.deleteContext(r.Context(), session); err != nil {
+			return nil, err
+		}
+		session, err := s.remote.create(r.Context(), r, name)
+		if err != nil {
+			return nil, err
+		}
+		s.cache(session)
+		return session, nil
+	}
+	session.SetIsNew(false)
+	s.remote.touch(session, r)
+	s.remote.emit(EventLoaded, session)
+	return session, nil
+}
+
+// AddListener registers a listener for the events of the RedisStore and of cache hits.
+func (s *TieredStore) AddListener(listener Listener) {
+	s.remote.AddListener(listener)
+}
+
+// New creates a new session in Redis and caches it.
+func (s *TieredStore) New(name string) (*Session, error) {
+	return s.newContext(context.Background(), name)
+}
+
+// newContext is New sending its commands with ctx.
+func (s *TieredStore) newContext(ctx context.Context, name string) (*Session, error) {
+	session, err := s.remote.newContext(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	s.cache(session)
+	return session, nil
+}
+
+// Save writes the session through to Redis and invalidates the copies of other instances.
+func (s *TieredStore) Save(session *Session) error {
+	return s.saveContext(context.Background(), session)
+}
+
+// saveContext is Save sending its commands with ctx.
+func (s *TieredStore) saveContext(ctx context.Context, session *Session) error {
+	if err := s.remote.saveContext(ctx, session); err != nil {
+		return err
+	}
+	s.cache(session)
+	// The session is saved, other instances serve their copy until it's older than the cache TTL.
+	_ = s.publish(ctx, session.GetID())
+	return nil
+}
+
+// Delete removes the session from Redis and from the local caches of all instances.
+// If other instances can't be told to drop their copy, the session is deleted from Redis
+// but an error is returned, as they may serve it until it's older than the cache TTL.
+func (s *TieredStore) Delete(session *Session) error {
+	return s.deleteContext(context.Background(), session)
+}
+
+// deleteContext is Delete sending its commands with ctx.
+func (s *TieredStore) deleteContext(ctx context.Context, session *Session) error {
+	if err := s.remote.deleteContext(ctx, session); err != nil {
+		return err
+	}
+	s.drop(session.GetID())
+	return s.publish(ctx, session.GetID())
+}
+
+// Incr increments the value of key in Redis and invalidates the copies of other instances.
+func (s *TieredStore) Incr(session *Session, key string, delta int64) (int64, error) {
+	n, err := s.remote.Incr(session, key, delta)
+	if err != nil {
+		return 0, err
+	}
+	s.cache(session)
+	_ = s.publish(context.Background(), session.GetID())
+	return n, nil
+}
+
+// BindUser binds the session to the user in Redis.
+func (s *TieredStore) BindUser(session *Session, userID string) error {
+	if err := s.remote.BindUser(session, userID); err != nil {
+		return err
+	}
+	s.cache(session)
+	_ = s.publish(context.Background(), session.GetID())
+	return nil
+}
+
+// ListByUser returns the sessions bound to the user, always read from Redis.
+func (s *TieredStore) ListByUser(userID string) ([]*Session, error) {
+	return s.remote.ListByUser(userID)
+}
+
+// RevokeUser deletes all sessions bound to the user from Redis and from the local caches,
+// like Delete it returns an error if other instances can't be told to drop their copies.
+func (s *TieredStore) RevokeUser(userID string) error {
+	sessions, err := s.remote.ListByUser(userID)
+	if err != nil {
+		return err
+	}
+	if err := s.remote.RevokeUser(userID); err != nil {
+		return err
+	}
+	var errs []error
+	for _, session := range sessions {
+		s.drop(session.GetID())
+		if err := s.publish(context.Background(), session.GetID()); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Range calls fn for every session in Redis until fn returns false.
+func (s *TieredStore) Range(ctx context.Context, fn func(session *Session) bool) error {
+	return s.remote.Range(ctx, fn)
+}
+
+// Count returns the number of sessions in Redis.
+func (s *TieredStore) Count() (int, error) {
+	return s.remote.Count()
+}
+
+// List returns a page of sessions read from Redis.
+func (s *TieredStore) List(cursor string, limit int) ([]*Session, string, error) {
+	return s.remote.List(cursor, limit)
+}
+
+// Load returns the session with the id read from Redis, or ErrNotFound.
+func (s *TieredStore) Load(id string) (*Session, error) {
+	return s.remote.Load(id)
+}
+
+// DeleteByID deletes the session with the id from Redis and from the local caches,
+// like Delete it returns an error if other instances can't be told to drop their copy.
+func (s *TieredStore) DeleteByID(id string) error {
+	if err := s.remote.DeleteByID(id); err != nil {
+		return err
+	}
+	s.drop(id)
+	return s.publish(context.Background(), id)
+}
+
+// cached returns the session with the id from the local cache if it's fresh.
+func (s *TieredStore) cached(id string) (*Session, bool) {
+	s.mutex.Lock()
+	loadedAt, ok := s.loadedAt[id]
+	s.mutex.Unlock()
+	if !ok || time.Since(loadedAt) > s.ttl {
+		return nil, false
+	}
+	s.local.mutex.RLock()
+	session, ok := s.local.sessions[id]
+	s.local.mutex.RUnlock()
+	return session, ok
+}
+
+func (s *TieredStore) cache(session *Session) {
+	s.mutex.Lock()
+	s.loadedAt[session.GetID()] = time.Now()
+	s.mutex.Unlock()
+	_ = s.local.Save(session)
+}
+
+func (s *TieredStore) drop(id string) {
+	s.mutex.Lock()
+	delete(s.loadedAt, id)
+	s.mutex.Unlock()
+	s.local.mutex.Lock()
+	delete(s.local.sessions, id)
+	s.local.mutex.Unlock()
+}
+
+// publish tells other instances to drop their copy of the session.
+// Messages are "<instance> <session id>".
+// Failures are logged and count towards the health of Redis, other instances serve their copy
+// until it's older than the cache TTL.
+func (s *TieredStore) publish(ctx context.Context, id string) error {
+	err := s.remote.guard(func() error {
+		return redisError(s.remote.client.Publish(ctx, s.channel, s.instance+" "+id).Err())
+	})
+	if err != nil {
+		s.remote.logger.Warn("sessions: publishing session invalidation failed",
+			sessionAttr(id), slog.Any("error", err))
+		return fmt.Errorf("failed to publish session invalidation: %w", err)
+	}
+	return nil
+}
+
+// listen drops sessions invalidated by other instances.
+func (s *TieredStore) listen(messages <-chan *redis.Message) {
+	for msg := range messages {
+		instance, id, ok := strings.Cut(msg.Payload, " ")
+		if !ok || instance == s.instance {
+			continue
+		}
+		s.drop(id)
+	}
+}
+
+// evict periodically drops sessions that have been cached longer than the TTL.
+func (s *TieredStore) evict() {
+	ticker := time.NewTicker(s.ttl)
+	defer ticker.Stop()
+	for range ticker.C {
+		var stale []string
+		s.mutex.Lock()
+		for id, loadedAt := range s.loadedAt {
+			if time.Since(loadedAt) > s.ttl {
+				stale = append(stale, id)
+			}
+		}
+		s.mutex.Unlock()
+		for _, id := range stale {
+			s.drop(id)
+		}
+	}
+}
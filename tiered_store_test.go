@@ -0,0 +1,90 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTieredStore_Invalidation(t *testing.T) {
+	client := setupRedisClient()
+	remote, _ := NewRedisStore(client)
+	first, err := NewTieredStore(remote.(*RedisStore), WithTieredTTL(time.Minute))
+	assert.NoError(t, err)
+	second, err := NewTieredStore(remote.(*RedisStore), WithTieredTTL(time.Minute))
+	assert.NoError(t, err)
+
+	session, _ := first.New("tiered_session")
+	session.SetValue("name", "Coco")
+	assert.NoError(t, first.Save(session))
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.AddCookie(&http.Cookie{Name: "tiered_session", Value: session.GetID()})
+
+	// second 从 Redis 读取并缓存, 再次读取命中 L1
+	cached, err := second.Get(req, "tiered_session")
+	assert.NoError(t, err)
+	assert.Equal(t, "Coco", cached.GetValueByKey("name"))
+	again, _ := second.Get(req, "tiered_session")
+	assert.Same(t, cached, again)
+
+	// first 写入后, second 的 L1 缓存失效
+	session.SetValue("name", "Bella")
+	assert.NoError(t, first.Save(session))
+	assert.Eventually(t, func() bool {
+		updated, err := second.Get(req, "tiered_session")
+		return err == nil && updated.GetValueByKey("name") == "Bella"
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, first.Delete(session))
+	assert.Eventually(t, func() bool {
+		deleted, err := second.Get(req, "tiered_session")
+		return err == nil && deleted.IsNew()
+	}, time.Second, 10*time.Millisecond)
+}
+
+// failPublish 让 PUBLISH 命令像网络故障一样失败, 其他命令正常执行
+type failPublish struct{}
+
+func (failPublish) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (failPublish) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if cmd.Name() == "publish" {
+			err := &net.OpError{Op: "write", Net: "tcp", Err: errors.New("broken pipe")}
+			cmd.SetErr(err)
+			return err
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (failPublish) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestTieredStore_DeleteReturnsPublishError(t *testing.T) {
+	client := setupRedisClient()
+	remote, _ := NewRedisStore(client)
+	store, err := NewTieredStore(remote.(*RedisStore))
+	assert.NoError(t, err)
+	session, _ := store.New("tiered_session")
+	assert.NoError(t, store.(UserIndex).BindUser(session, "publish-failure-user"))
+
+	client.AddHook(failPublish{})
+	// 保存不受影响, 其他实例的缓存过期后读取新值
+	session.SetValue("name", "Coco")
+	assert.NoError(t, store.Save(session))
+	// 删除和撤销必须报告其他实例可能仍在使用缓存
+	assert.ErrorIs(t, store.(UserIndex).RevokeUser("publish-failure-user"), ErrStoreUnavailable)
+	session, _ = store.New("tiered_session")
+	assert.ErrorIs(t, store.Delete(session), ErrStoreUnavailable)
+	_, err = remote.(Inspector).Load(session.GetID())
+	assert.ErrorIs(t, err, ErrNotFound, "the session is deleted from Redis")
+}
@@ -5,26 +5,77 @@ import (
 	"crypto/rand"
 	"encoding/gob"
 	"fmt"
+	"log/slog"
 	"math/big"
 	"net/http"
+	"strings"
 	"time"
 )
 
+// idLetters are the characters a session ID is made of.
+const idLetters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz=!@#$%^&*()-_+"
+
 // generateRandomID adopted from https://gist.github.com/dopey/c69559607800d2f2f90b1b1ed4e550fb
 func generateRandomID(n int) (string, error) {
-	const letters = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz=!@#$%^&*()-_+"
 	ret := make([]byte, n)
 	for i := 0; i < n; i++ {
-		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
+		num, err := rand.Int(rand.Reader, big.NewInt(int64(len(idLetters))))
 		if err != nil {
 			return "", fmt.Errorf("failed to generate session id: %v", err)
 		}
-		ret[i] = letters[num.Int64()]
+		ret[i] = idLetters[num.Int64()]
 	}
 
 	return string(ret), nil
 }
 
+// toInt64 converts a numeric session value to an int64, values decoded from JSON are float64.
+// Other values are 0.
+func toInt64(v interface{}) int64 {
+	switch n := v.(type) {
+	case int:
+		return int64(n)
+	case int8:
+		return int64(n)
+	case int16:
+		return int64(n)
+	case int32:
+		return int64(n)
+	case int64:
+		return n
+	case uint:
+		return int64(n)
+	case uint8:
+		return int64(n)
+	case uint16:
+		return int64(n)
+	case uint32:
+		return int64(n)
+	case uint64:
+		return int64(n)
+	case float32:
+		return int64(n)
+	case float64:
+		return int64(n)
+	default:
+		return 0
+	}
+}
+
+// isSessionIDValid reports whether id could have been generated by generateRandomID,
+// IDs coming from cookies must be checked before they are used as file names or keys.
+func isSessionIDValid(id string) bool {
+	if id == "" || len(id) > 256 {
+		return false
+	}
+	for _, c := range id {
+		if !strings.ContainsRune(idLetters, c) {
+			return false
+		}
+	}
+	return true
+}
+
 // deepCopyMap performs a deep copy of the given map m.
 //
 // learn more: https://davidzhu.xyz/post/golang/basics/014-gob-json-encoding/#27-gobregister-method
@@ -97,3 +148,175 @@ func WithGCInterval(interval time.Duration) func(store *MemoryStore) {
 		store.gcInterval = interval
 	}
 }
+
+// WithTrustedProxies sets the proxies whose X-Forwarded-For header is trusted
+// when capturing the client IP of a session.
+// Proxies are IP addresses or networks in CIDR notation, e.g. "10.0.0.0/8".
+func WithTrustedProxies(proxies ...string) func(store *MemoryStore) {
+	return func(store *MemoryStore) {
+		networks, err := parseCIDRs(proxies)
+		if err != nil {
+			panic(err)
+		}
+		store.trustedProxies = networks
+	}
+}
+
+// WithFingerprint binds every new session to a fingerprint of its client,
+// see FingerprintPolicy.
+func WithFingerprint(policy FingerprintPolicy) func(store *MemoryStore) {
+	return func(store *MemoryStore) {
+		store.fingerprint = &policy
+	}
+}
+
+// WithSnapshotFile restores sessions from the snapshot file at path when the store is created,
+// and writes a new snapshot to it every interval, so sessions survive restarts.
+func WithSnapshotFile(path string, interval time.Duration) func(store *MemoryStore) {
+	return func(store *MemoryStore) {
+		if path == "" {
+			panic("snapshot path cannot be empty")
+		}
+		if interval <= 0 {
+			panic("snapshot interval must be greater than 0")
+		}
+		store.snapshotPath = path
+		store.snapshotInterval = interval
+	}
+}
+
+// WithSnapshotCodec sets the codec snapshots are written with, the JSON Serializer by default.
+// Snapshots hold the values of sessions, use an EncryptedCodec if they are sensitive.
+func WithSnapshotCodec(codec Codec) func(store *MemoryStore) {
+	return func(store *MemoryStore) {
+		if codec == nil {
+			panic("codec cannot be nil")
+		}
+		store.snapshotCodec = codec
+	}
+}
+
+// WithLimits limits the size of sessions, Save returns a *LimitError for sessions exceeding them.
+// MaxSize applies to sessions serialized as JSON, the store keeps them as is.
+func WithLimits(limits Limits) func(store *MemoryStore) {
+	return func(store *MemoryStore) {
+		store.limits = validateLimits(limits)
+	}
+}
+
+// WithLogger sets the logger of the store, nothing is logged by default.
+// Session IDs are logged as hashes.
+func WithLogger(logger *slog.Logger) func(store *MemoryStore) {
+	return func(store *MemoryStore) {
+		store.setLogger(logger)
+	}
+}
+
+// Option functions for customizing RedisStore
+
+// WithRedisTrustedProxies sets the proxies whose X-Forwarded-For header is trusted
+// when capturing the client IP of a session.
+func WithRedisTrustedProxies(proxies ...string) func(store *RedisStore) {
+	return func(store *RedisStore) {
+		networks, err := parseCIDRs(proxies)
+		if err != nil {
+			panic(err)
+		}
+		store.trustedProxies = networks
+	}
+}
+
+// WithRedisKeyPrefix sets the prefix of all keys written by the store, e.g. "session:",
+// so sessions can be told apart from other keys in the same database.
+func WithRedisKeyPrefix(prefix string) func(store *RedisStore) {
+	return func(store *RedisStore) {
+		store.keyPrefix = prefix
+	}
+}
+
+// WithRedisExpiryEvents emits EventExpired when Redis expires a session,
+// using keyspace notifications. The server must have expired events enabled,
+// e.g. "notify-keyspace-events Ex", the store doesn't change the server configuration.
+func WithRedisExpiryEvents() func(store *RedisStore) {
+	return func(store *RedisStore) {
+		store.expiryEvents = true
+	}
+}
+
+// WithRedisFingerprint binds every new session to a fingerprint of its client,
+// see FingerprintPolicy.
+func WithRedisFingerprint(policy FingerprintPolicy) func(store *RedisStore) {
+	return func(store *RedisStore) {
+		store.fingerprint = &policy
+	}
+}
+
+// WithRedisFailurePolicy sets what the store does when Redis is unavailable, FailClosed by default.
+func WithRedisFailurePolicy(policy FailurePolicy) func(store *RedisStore) {
+	return func(store *RedisStore) {
+		if policy < FailClosed || policy > FailLocal {
+			panic("invalid failure policy")
+		}
+		store.failurePolicy = policy
+	}
+}
+
+// WithRedisCircuitBreaker stops sending commands to Redis for cooldown after threshold consecutive failures,
+// calls fail with ErrCircuitOpen meanwhile, or are served according to the failure policy.
+func WithRedisCircuitBreaker(threshold int, cooldown time.Duration) func(store *RedisStore) {
+	return func(store *RedisStore) {
+		if threshold <= 0 {
+			panic("circuit breaker threshold must be greater than 0")
+		}
+		if cooldown <= 0 {
+			panic("circuit breaker cooldown must be greater than 0")
+		}
+		store.health.threshold = threshold
+		store.health.cooldown = cooldown
+	}
+}
+
+// WithRedisCorruptPolicy sets what Get does with sessions whose data can't be decoded, CorruptFail by default.
+// CorruptReplace only deletes the data of corrupt sessions if the store has a key prefix,
+// see WithRedisKeyPrefix, since other keys of the database can't be told apart from sessions.
+func WithRedisCorruptPolicy(policy CorruptPolicy) func(store *RedisStore) {
+	return func(store *RedisStore) {
+		if policy < CorruptFail || policy > CorruptReplace {
+			panic("invalid corrupt policy")
+		}
+		store.corruptPolicy = policy
+	}
+}
+
+// WithRedisOnDecodeError sets a function called with the ID of every session whose data can't be decoded,
+// e.g. to report it. The ID is a credential, don't log it as is.
+func WithRedisOnDecodeError(fn func(id string, err error)) func(store *RedisStore) {
+	return func(store *RedisStore) {
+		store.onDecodeError = fn
+	}
+}
+
+// WithRedisCodec sets the codec sessions are written to Redis with, e.g. a CompressedCodec,
+// the JSON Serializer by default. A TieredStore uses the codec of its RedisStore.
+func WithRedisCodec(codec Codec) func(store *RedisStore) {
+	return func(store *RedisStore) {
+		if codec == nil {
+			panic("codec cannot be nil")
+		}
+		store.serializer = codec
+	}
+}
+
+// WithRedisLimits limits the size of sessions, see WithLimits. MaxSize applies to the value written to Redis.
+func WithRedisLimits(limits Limits) func(store *RedisStore) {
+	return func(store *RedisStore) {
+		store.limits = validateLimits(limits)
+	}
+}
+
+// WithRedisLogger sets the logger of the store and of TieredStores using it, see WithLogger.
+func WithRedisLogger(logger *slog.Logger) func(store *RedisStore) {
+	return func(store *RedisStore) {
+		store.setLogger(logger)
+	}
+}